@@ -2,11 +2,13 @@ package main
 
 import (
 	"os"
+	"syscall"
 	"time"
 
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"os/signal"
 
 	auth "github.com/AcalephStorage/go-auth"
 	log "github.com/Sirupsen/logrus"
@@ -28,6 +30,21 @@ const (
 
 	addressFlag                   = "address"
 	tillerAddressFlag             = "tiller-address"
+	tillerTLSFlag                 = "tiller-tls"
+	tillerTLSVerifyFlag           = "tiller-tls-verify"
+	tillerTLSCertFlag             = "tiller-tls-cert"
+	tillerTLSKeyFlag              = "tiller-tls-key"
+	tillerTLSCACertFlag           = "tiller-tls-ca-cert"
+	tillerTLSHostnameFlag         = "tiller-tls-hostname"
+	tillerReadTimeoutFlag         = "tiller-read-timeout"
+	tillerWriteTimeoutFlag        = "tiller-write-timeout"
+	tillerTestTimeoutFlag         = "tiller-test-timeout"
+	tillerRetryAttemptsFlag       = "tiller-retry-attempts"
+	tillerRetryBackoffFlag        = "tiller-retry-backoff"
+	tillerRetryMaxBackoffFlag     = "tiller-retry-max-backoff"
+	tillerBreakerThresholdFlag    = "tiller-breaker-threshold"
+	tillerBreakerCooldownFlag     = "tiller-breaker-cooldown"
+	clustersConfigFlag            = "clusters-config"
 	helmRepoFileFlag              = "helm-repo-file"
 	helmCacheDirFlag              = "helm-cache-dir"
 	helmRepoCacheLifetimeFlag     = "helm-repo-cache-lifetime"
@@ -38,6 +55,15 @@ const (
 	clientIDFlag                  = "client-id"
 	clientSecretFlag              = "client-secret"
 	clientSecretBase64EncodedFlag = "client-secret-base64-encoded"
+	operationWorkersFlag          = "operation-workers"
+	operationQueueSizeFlag        = "operation-queue-size"
+	operationRetentionFlag        = "operation-retention"
+	watchIntervalFlag             = "watch-interval"
+	webhookConfigFlag             = "webhook-config"
+	auditLogFileFlag              = "audit-log-file"
+	auditLogStdoutFlag            = "audit-log-stdout"
+	authzPolicyFileFlag           = "authz-policy-file"
+	trustedProxiesFlag            = "trusted-proxies"
 	debugFlag                     = "debug"
 	insecure                      = "insecure"
 
@@ -49,7 +75,8 @@ var (
 	version = "dev"
 
 	corsAllowedMethods = []string{"POST", "GET", "OPTIONS", "PUT", "DELETE"}
-	corsAllowedHeaders = []string{"Authorization", "Accept", "Content-Type"}
+	corsAllowedHeaders = []string{"Authorization", "Accept", "Content-Type", filter.RequestIDHeader}
+	corsExposedHeaders = []string{filter.RequestIDHeader, "Location", "Retry-After"}
 )
 
 func main() {
@@ -69,6 +96,89 @@ func main() {
 			EnvVar: "RUDDER_TILLER_ADDRESS",
 			Value:  "localhost:44134",
 		},
+		cli.BoolFlag{
+			Name:   tillerTLSFlag,
+			Usage:  "connect to tiller using TLS",
+			EnvVar: "RUDDER_TILLER_TLS",
+		},
+		cli.BoolFlag{
+			Name:   tillerTLSVerifyFlag,
+			Usage:  "connect to tiller using TLS and verify the tiller certificate. implies tiller-tls",
+			EnvVar: "RUDDER_TILLER_TLS_VERIFY",
+		},
+		cli.StringFlag{
+			Name:   tillerTLSCertFlag,
+			Usage:  "client certificate file for the tiller TLS connection",
+			EnvVar: "RUDDER_TILLER_TLS_CERT",
+		},
+		cli.StringFlag{
+			Name:   tillerTLSKeyFlag,
+			Usage:  "client key file for the tiller TLS connection",
+			EnvVar: "RUDDER_TILLER_TLS_KEY",
+		},
+		cli.StringFlag{
+			Name:   tillerTLSCACertFlag,
+			Usage:  "CA certificate file used to verify the tiller certificate",
+			EnvVar: "RUDDER_TILLER_TLS_CA_CERT",
+		},
+		cli.StringFlag{
+			Name:   tillerTLSHostnameFlag,
+			Usage:  "server name used to verify the tiller certificate. defaults to the tiller host",
+			EnvVar: "RUDDER_TILLER_TLS_HOSTNAME",
+		},
+		cli.DurationFlag{
+			Name:   tillerReadTimeoutFlag,
+			Usage:  "deadline of tiller calls listing or getting releases. should be in duration format (eg. 30s)",
+			EnvVar: "RUDDER_TILLER_READ_TIMEOUT",
+			Value:  client.DefaultTimeouts.Read,
+		},
+		cli.DurationFlag{
+			Name:   tillerWriteTimeoutFlag,
+			Usage:  "deadline of tiller calls installing, upgrading, rolling back or uninstalling releases. extended to the request timeout if longer. should be in duration format (eg. 10m)",
+			EnvVar: "RUDDER_TILLER_WRITE_TIMEOUT",
+			Value:  client.DefaultTimeouts.Write,
+		},
+		cli.DurationFlag{
+			Name:   tillerTestTimeoutFlag,
+			Usage:  "deadline of tiller calls running release tests. extended to the request timeout if longer. should be in duration format (eg. 10m)",
+			EnvVar: "RUDDER_TILLER_TEST_TIMEOUT",
+			Value:  client.DefaultTimeouts.Test,
+		},
+		cli.IntFlag{
+			Name:   tillerRetryAttemptsFlag,
+			Usage:  "max attempts of tiller calls reading releases or the version while tiller is unavailable. 1 disables retries. mutating calls are never retried",
+			EnvVar: "RUDDER_TILLER_RETRY_ATTEMPTS",
+			Value:  client.DefaultCallOptions.Retry.Attempts,
+		},
+		cli.DurationFlag{
+			Name:   tillerRetryBackoffFlag,
+			Usage:  "wait before the first retry, doubled for every following retry and jittered. should be in duration format (eg. 250ms)",
+			EnvVar: "RUDDER_TILLER_RETRY_BACKOFF",
+			Value:  client.DefaultCallOptions.Retry.Backoff,
+		},
+		cli.DurationFlag{
+			Name:   tillerRetryMaxBackoffFlag,
+			Usage:  "max wait between retries. should be in duration format (eg. 2s)",
+			EnvVar: "RUDDER_TILLER_RETRY_MAX_BACKOFF",
+			Value:  client.DefaultCallOptions.Retry.MaxBackoff,
+		},
+		cli.IntFlag{
+			Name:   tillerBreakerThresholdFlag,
+			Usage:  "consecutive tiller calls finding tiller unavailable before calls fail fast. 0 disables the circuit breaker",
+			EnvVar: "RUDDER_TILLER_BREAKER_THRESHOLD",
+			Value:  client.DefaultCallOptions.Breaker.Threshold,
+		},
+		cli.DurationFlag{
+			Name:   tillerBreakerCooldownFlag,
+			Usage:  "how long tiller calls fail fast before tiller is tried again. should be in duration format (eg. 30s)",
+			EnvVar: "RUDDER_TILLER_BREAKER_COOLDOWN",
+			Value:  client.DefaultCallOptions.Breaker.Cooldown,
+		},
+		cli.StringFlag{
+			Name:   clustersConfigFlag,
+			Usage:  "clusters config file defining named tiller backends. if set, tiller-address and the tiller-tls flags are ignored",
+			EnvVar: "RUDDER_CLUSTERS_CONFIG",
+		},
 		cli.StringFlag{
 			Name:   helmRepoFileFlag,
 			Usage:  "helm repo file",
@@ -123,6 +233,55 @@ func main() {
 			Usage:  "enable this flag to specify that the client-secret is base64 encoded",
 			EnvVar: "RUDDER_CLIENT_BASE64_ENCODED",
 		},
+		cli.IntFlag{
+			Name:   operationWorkersFlag,
+			Usage:  "number of asynchronous release operations that can run at the same time",
+			EnvVar: "RUDDER_OPERATION_WORKERS",
+			Value:  4,
+		},
+		cli.IntFlag{
+			Name:   operationQueueSizeFlag,
+			Usage:  "max number of pending asynchronous release operations",
+			EnvVar: "RUDDER_OPERATION_QUEUE_SIZE",
+			Value:  100,
+		},
+		cli.DurationFlag{
+			Name:   operationRetentionFlag,
+			Usage:  "how long finished asynchronous release operations are kept. should be in duration format (eg. 1h)",
+			EnvVar: "RUDDER_OPERATION_RETENTION",
+			Value:  time.Hour,
+		},
+		cli.DurationFlag{
+			Name:   watchIntervalFlag,
+			Usage:  "how often tiller is polled while clients are watching releases. should be in duration format (eg. 5s)",
+			EnvVar: "RUDDER_WATCH_INTERVAL",
+			Value:  5 * time.Second,
+		},
+		cli.StringFlag{
+			Name:   webhookConfigFlag,
+			Usage:  "webhook config file. release lifecycle events are sent to the webhooks defined in it",
+			EnvVar: "RUDDER_WEBHOOK_CONFIG",
+		},
+		cli.StringFlag{
+			Name:   auditLogFileFlag,
+			Usage:  "file the audit log is appended to as JSON lines. if not set, only the most recent records are kept in memory",
+			EnvVar: "RUDDER_AUDIT_LOG_FILE",
+		},
+		cli.BoolFlag{
+			Name:   auditLogStdoutFlag,
+			Usage:  "also write the audit log to stdout as JSON lines",
+			EnvVar: "RUDDER_AUDIT_LOG_STDOUT",
+		},
+		cli.StringFlag{
+			Name:   authzPolicyFileFlag,
+			Usage:  "authorization policy file. if set, requests are only allowed if a policy in it allows them",
+			EnvVar: "RUDDER_AUTHZ_POLICY_FILE",
+		},
+		cli.StringFlag{
+			Name:   trustedProxiesFlag,
+			Usage:  "comma separated IPs or CIDRs of the proxies trusted to set X-Forwarded-For. the request's remote address is used otherwise",
+			EnvVar: "RUDDER_TRUSTED_PROXIES",
+		},
 		cli.BoolFlag{
 			Name:   debugFlag,
 			Hidden: true,
@@ -151,6 +310,7 @@ func startRudder(ctx *cli.Context) error {
 	createBasicFilters(container, isDebug)
 
 	// add auth filter
+	authenticated := false
 	if !ctx.Bool(insecure) {
 		username := ctx.String(basicAuthUsernameFlag)
 		password := ctx.String(basicAuthPasswordFlag)
@@ -158,19 +318,78 @@ func startRudder(ctx *cli.Context) error {
 		clientID := ctx.String(clientIDFlag)
 		clientSecret := ctx.String(clientSecretFlag)
 		secretIsBase64Encoded := ctx.Bool(clientSecretBase64EncodedFlag)
-		createAuthFilter(container, username, password, oidcIssuerURL, clientID, clientSecret, secretIsBase64Encoded)
+		authenticated = createAuthFilter(container, username, password, oidcIssuerURL, clientID, clientSecret, secretIsBase64Encoded)
+	}
+	// policies are matched against the authenticated user, which anyone could claim to be otherwise
+	authzPolicyFile := ctx.String(authzPolicyFileFlag)
+	if authzPolicyFile != "" && !authenticated {
+		log.Fatal("an authz policy file needs basic auth or OIDC to be enabled, and can't be used with --insecure")
 	}
 
-	// add `repo` resource
+	// operations, webhooks and the audit log
+	workers := ctx.Int(operationWorkersFlag)
+	queueSize := ctx.Int(operationQueueSizeFlag)
+	retention := ctx.Duration(operationRetentionFlag)
+	operationController := controller.NewOperationController(workers, queueSize, retention)
+	webhookConfig := ctx.String(webhookConfigFlag)
+	webhookController := createWebhookController(webhookConfig)
+	auditLogFile := ctx.String(auditLogFileFlag)
+	auditLogStdout := ctx.Bool(auditLogStdoutFlag)
+	auditController := createAuditController(auditLogFile, auditLogStdout)
+
+	// clusters
 	repoFile := ctx.String(helmRepoFileFlag)
 	cacheDir := ctx.String(helmCacheDirFlag)
 	cacheLifetime := ctx.Duration(helmRepoCacheLifetimeFlag)
 	repoController := createRepoController(repoFile, cacheDir, cacheLifetime)
-	registerRepoResource(container, repoController)
-
-	// add `release` resource
+	clustersConfig := ctx.String(clustersConfigFlag)
 	tillerAddress := ctx.String(tillerAddressFlag)
-	registerReleaseResource(container, repoController, tillerAddress)
+	tillerTLS := client.TLSOptions{
+		Enable:     ctx.Bool(tillerTLSFlag),
+		Verify:     ctx.Bool(tillerTLSVerifyFlag),
+		CertFile:   ctx.String(tillerTLSCertFlag),
+		KeyFile:    ctx.String(tillerTLSKeyFlag),
+		CACertFile: ctx.String(tillerTLSCACertFlag),
+		ServerName: ctx.String(tillerTLSHostnameFlag),
+	}
+	tillerCallOptions := client.CallOptions{
+		Timeouts: client.Timeouts{
+			Read:  ctx.Duration(tillerReadTimeoutFlag),
+			Write: ctx.Duration(tillerWriteTimeoutFlag),
+			Test:  ctx.Duration(tillerTestTimeoutFlag),
+		},
+		Retry: client.RetryOptions{
+			Attempts:   ctx.Int(tillerRetryAttemptsFlag),
+			Backoff:    ctx.Duration(tillerRetryBackoffFlag),
+			MaxBackoff: ctx.Duration(tillerRetryMaxBackoffFlag),
+		},
+		Breaker: client.BreakerOptions{
+			Threshold: ctx.Int(tillerBreakerThresholdFlag),
+			Cooldown:  ctx.Duration(tillerBreakerCooldownFlag),
+		},
+	}
+	watchInterval := ctx.Duration(watchIntervalFlag)
+	clusterController := createClusterController(clustersConfig, tillerAddress, tillerTLS, tillerCallOptions, repoController, webhookController, watchInterval)
+
+	// audit and authz filters. the authz filter needs the clusters to find the namespace of releases
+	trustedProxies := ctx.String(trustedProxiesFlag)
+	auditFilter := createAuditFilter(auditController, operationController, clusterController, trustedProxies)
+	authzFilter := createAuthzFilter(authzPolicyFile, clusterController)
+
+	// add `operation`, `webhook` and `audit` resources
+	registerOperationResource(container, operationController, authzFilter)
+	registerWebhookResource(container, webhookController, authzFilter)
+	registerAuditResource(container, auditController, authzFilter)
+
+	// add `health` resource
+	registerHealthResource(container, clusterController)
+
+	// add `repo` resource
+	registerRepoResource(container, repoController, authzFilter)
+
+	// add `release` and `cluster` resources. the cluster resource serves the releases of every cluster
+	releaseResource := registerReleaseResource(container, clusterController, operationController, auditFilter, authzFilter)
+	registerClusterResource(container, clusterController, authzFilter, releaseResource)
 
 	// add swagger service
 	swaggerUIPath := ctx.String(swaggerUIPathFlag)
@@ -198,6 +417,10 @@ func initializeLogger(isDebug bool) {
 }
 
 func createBasicFilters(container *restful.Container, isDebug bool) {
+	// request id
+	requestIDFilter := filter.NewRequestIDFilter()
+	container.Filter(requestIDFilter.RequestID)
+	log.Info("Request ID filter added.")
 	// debug filter
 	if isDebug {
 		debugFilter := filter.NewDebugFilter()
@@ -208,6 +431,7 @@ func createBasicFilters(container *restful.Container, isDebug bool) {
 	cors := restful.CrossOriginResourceSharing{
 		AllowedMethods: corsAllowedMethods,
 		AllowedHeaders: corsAllowedHeaders,
+		ExposeHeaders:  corsExposedHeaders,
 		Container:      container,
 	}
 	container.Filter(cors.Filter)
@@ -217,27 +441,33 @@ func createBasicFilters(container *restful.Container, isDebug bool) {
 	log.Info("OPTIONS filter added.")
 }
 
-func createAuthFilter(container *restful.Container, username, password, oidcIssuerURL, clientID, clientSecret string, isBase64Encoded bool) {
+func createAuthFilter(container *restful.Container, username, password, oidcIssuerURL, clientID, clientSecret string, isBase64Encoded bool) bool {
 	// supported auth
 	var supportedAuth []auth.Auth
 	// enable basic auth of username and password are defined
-	if username != "" && password != "" {
+	basicAuth := username != "" && password != ""
+	if basicAuth {
 		supportedAuth = append(supportedAuth, auth.NewBasicAuth(username, password))
 	}
 	// enable oidc auth if oidc issuer url or client secret is defined
-	if oidcIssuerURL != "" || clientSecret != "" {
+	oidc := oidcIssuerURL != "" || clientSecret != ""
+	if oidc {
 		oidcAuth := auth.NewOIDCAuth(oidcIssuerURL, clientID, clientSecret, isBase64Encoded)
 		supportedAuth = append(supportedAuth, oidcAuth)
 	}
-	// don't include swagger to exceptions
+	// swagger and the readiness check don't need authentication
 	exceptions := []string{
 		"/apidocs.json",
 		"/swagger",
+		"/api/v1/health",
 	}
 
+	// the identity filter keeps the caller once the auth filter has verified it
 	authFilter := auth.NewAuthFilter(supportedAuth, exceptions)
-	container.Filter(authFilter.Filter)
+	identityFilter := filter.NewIdentityFilter(authFilter.Filter, exceptions, basicAuth, oidc)
+	container.Filter(identityFilter.Identify)
 	log.Info("Auth filter added")
+	return basicAuth || oidc
 }
 
 func createRepoController(repoFileURL, cacheDir string, cacheLife time.Duration) *controller.RepoController {
@@ -257,18 +487,151 @@ func createRepoController(repoFileURL, cacheDir string, cacheLife time.Duration)
 	return repoController
 }
 
-func registerRepoResource(container *restful.Container, repoController *controller.RepoController) {
-	repoResource := resource.NewRepoResource(repoController)
+func registerRepoResource(container *restful.Container, repoController *controller.RepoController, authzFilter *filter.AuthzFilter) {
+	repoResource := resource.NewRepoResource(repoController, authzFilter)
 	repoResource.Register(container)
 	log.Info("repo resource registered.")
 }
 
-func registerReleaseResource(container *restful.Container, repoController *controller.RepoController, tillerAddress string) {
-	tillerClient := client.NewTillerClient(tillerAddress)
-	releaseController := controller.NewReleaseController(tillerClient, repoController)
-	releaseResource := resource.NewReleaseResource(releaseController)
+func createWebhookController(webhookConfigFile string) *controller.WebhookController {
+	var webhookConfig controller.WebhookConfig
+	if webhookConfigFile != "" {
+		webhookConfigYAML, err := ioutil.ReadFile(webhookConfigFile)
+		if err != nil {
+			log.Fatalf("unable to read webhook config at %s", webhookConfigFile)
+		}
+		if err := yaml.Unmarshal(webhookConfigYAML, &webhookConfig); err != nil {
+			log.Fatal("unable to parse webhook config")
+		}
+	}
+	webhookController, err := controller.NewWebhookController(webhookConfig.Webhooks)
+	if err != nil {
+		log.WithError(err).Fatal("invalid webhook config")
+	}
+	log.Infof("%d webhook(s) configured.", len(webhookConfig.Webhooks))
+	return webhookController
+}
+
+func registerWebhookResource(container *restful.Container, webhookController *controller.WebhookController, authzFilter *filter.AuthzFilter) {
+	webhookResource := resource.NewWebhookResource(webhookController, authzFilter)
+	webhookResource.Register(container)
+	log.Info("webhook resource registered.")
+}
+
+func createAuditController(auditLogFile string, auditLogStdout bool) *controller.AuditController {
+	var reader controller.AuditReader = controller.NewMemoryAuditSink()
+	if auditLogFile != "" {
+		fileSink, err := controller.NewFileAuditSink(auditLogFile)
+		if err != nil {
+			log.WithError(err).Fatalf("unable to open audit log file at %s", auditLogFile)
+		}
+		reader = fileSink
+		go reopenOnHangup(fileSink)
+		log.Infof("audit log written to %s.", auditLogFile)
+	}
+	var sinks []controller.AuditSink
+	if auditLogStdout {
+		sinks = append(sinks, controller.NewStdoutAuditSink())
+		log.Info("audit log written to stdout.")
+	}
+	return controller.NewAuditController(reader, sinks...)
+}
+
+// reopenOnHangup reopens the audit log file on SIGHUP, sent by log rotation after moving the file
+func reopenOnHangup(fileSink *controller.FileAuditSink) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		if err := fileSink.Reopen(); err != nil {
+			log.WithError(err).Error("unable to reopen the audit log file")
+			continue
+		}
+		log.Info("audit log file reopened.")
+	}
+}
+
+func createAuditFilter(auditController *controller.AuditController, operationController *controller.OperationController, clusterController *controller.ClusterController, trustedProxies string) *filter.AuditFilter {
+	proxies, err := filter.ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		log.WithError(err).Fatal("unable to parse trusted proxies")
+	}
+	if len(proxies) > 0 {
+		log.Infof("X-Forwarded-For trusted from %d proxies.", len(proxies))
+	}
+	return filter.NewAuditFilter(auditController, operationController, clusterController.ReleaseNamespace, proxies)
+}
+
+func registerAuditResource(container *restful.Container, auditController *controller.AuditController, authzFilter *filter.AuthzFilter) {
+	auditResource := resource.NewAuditResource(auditController, authzFilter)
+	auditResource.Register(container)
+	log.Info("audit resource registered.")
+}
+
+func registerOperationResource(container *restful.Container, operationController *controller.OperationController, authzFilter *filter.AuthzFilter) {
+	operationResource := resource.NewOperationResource(operationController, authzFilter)
+	operationResource.Register(container)
+	log.Info("operation resource registered.")
+}
+
+func createClusterController(clustersConfigFile, tillerAddress string, tillerTLS client.TLSOptions, tillerCallOptions client.CallOptions, repoController *controller.RepoController, webhookController *controller.WebhookController, watchInterval time.Duration) *controller.ClusterController {
+	// without a clusters config, the tiller address is the only cluster
+	clusterConfig := controller.ClusterConfig{
+		Clusters: []*controller.Cluster{{Name: "default", TillerAddress: tillerAddress, TLSOptions: tillerTLS}},
+	}
+	if clustersConfigFile != "" {
+		clustersConfigYAML, err := ioutil.ReadFile(clustersConfigFile)
+		if err != nil {
+			log.Fatalf("unable to read clusters config at %s", clustersConfigFile)
+		}
+		clusterConfig = controller.ClusterConfig{}
+		if err := yaml.Unmarshal(clustersConfigYAML, &clusterConfig); err != nil {
+			log.Fatal("unable to parse clusters config")
+		}
+	}
+	clusterController, err := controller.NewClusterController(&clusterConfig, repoController, webhookController, watchInterval, controller.NewTillerBackendFactory(tillerCallOptions))
+	if err != nil {
+		log.WithError(err).Fatal("unable to set up clusters")
+	}
+	return clusterController
+}
+
+func registerHealthResource(container *restful.Container, clusterController *controller.ClusterController) {
+	healthResource := resource.NewHealthResource(clusterController)
+	healthResource.Register(container)
+	log.Info("health resource registered.")
+}
+
+func registerClusterResource(container *restful.Container, clusterController *controller.ClusterController, authzFilter *filter.AuthzFilter, releaseResource *resource.ReleaseResource) {
+	clusterResource := resource.NewClusterResource(clusterController, authzFilter, releaseResource)
+	clusterResource.Register(container)
+	log.Info("cluster resource registered.")
+}
+
+func createAuthzFilter(policyFile string, clusterController *controller.ClusterController) *filter.AuthzFilter {
+	if policyFile == "" {
+		return nil
+	}
+	policyYAML, err := ioutil.ReadFile(policyFile)
+	if err != nil {
+		log.Fatalf("unable to read authz policy file at %s", policyFile)
+	}
+	var policyConfig controller.PolicyConfig
+	if err := yaml.Unmarshal(policyYAML, &policyConfig); err != nil {
+		log.Fatal("unable to parse authz policy file")
+	}
+	authzController, err := controller.NewAuthzController(policyConfig.Policies)
+	if err != nil {
+		log.WithError(err).Fatal("invalid authz policy")
+	}
+	log.Infof("Authz filter enabled with %d policies.", len(policyConfig.Policies))
+	return filter.NewAuthzFilter(authzController, clusterController.ReleaseNamespace, clusterController.DefaultCluster())
+}
+
+func registerReleaseResource(container *restful.Container, clusterController *controller.ClusterController, operationController *controller.OperationController, auditFilter *filter.AuditFilter, authzFilter *filter.AuthzFilter) *resource.ReleaseResource {
+	releaseResource := resource.NewReleaseResource(clusterController, operationController, auditFilter, authzFilter, version)
 	releaseResource.Register(container)
 	log.Info("release resource registered.")
+	return releaseResource
 }
 
 func registerSwagger(container *restful.Container, swaggerUIPath string) {
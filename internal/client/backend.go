@@ -0,0 +1,23 @@
+package client
+
+import (
+	"golang.org/x/net/context"
+	tiller "k8s.io/helm/pkg/proto/hapi/services"
+)
+
+// ReleaseBackend is the tiller release service as used by the release controller. TillerClient
+// is the implementation talking to tiller.
+type ReleaseBackend interface {
+	ListReleases(ctx context.Context, req *tiller.ListReleasesRequest) (*tiller.ListReleasesResponse, error)
+	InstallRelease(ctx context.Context, req *tiller.InstallReleaseRequest) (*tiller.InstallReleaseResponse, error)
+	UpdateRelease(ctx context.Context, req *tiller.UpdateReleaseRequest) (*tiller.UpdateReleaseResponse, error)
+	UninstallRelease(ctx context.Context, req *tiller.UninstallReleaseRequest) (*tiller.UninstallReleaseResponse, error)
+	GetReleaseContent(ctx context.Context, req *tiller.GetReleaseContentRequest) (*tiller.GetReleaseContentResponse, error)
+	GetReleaseStatus(ctx context.Context, req *tiller.GetReleaseStatusRequest) (*tiller.GetReleaseStatusResponse, error)
+	RollbackRelease(ctx context.Context, req *tiller.RollbackReleaseRequest) (*tiller.RollbackReleaseResponse, error)
+	GetHistory(ctx context.Context, req *tiller.GetHistoryRequest) (*tiller.GetHistoryResponse, error)
+	RunReleaseTest(ctx context.Context, req *tiller.TestReleaseRequest, handle func(*tiller.TestReleaseResponse) error) error
+	GetVersion(ctx context.Context) (*tiller.GetVersionResponse, error)
+}
+
+var _ ReleaseBackend = &TillerClient{}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/AcalephStorage/rudder/internal/util"
+)
+
+// RetryOptions configures the retries of read calls failing because tiller is unavailable.
+// Attempts includes the first call, so 1 disables retries. The backoff doubles with every retry,
+// up to MaxBackoff, and is jittered.
+type RetryOptions struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// BreakerOptions configures the circuit breaker. The circuit opens after Threshold consecutive
+// calls found tiller unavailable, and stays open for Cooldown. A Threshold of 0 disables it.
+type BreakerOptions struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// CallOptions are the deadlines, retries and circuit breaker of the calls to a tiller
+type CallOptions struct {
+	Timeouts Timeouts
+	Retry    RetryOptions
+	Breaker  BreakerOptions
+}
+
+// DefaultCallOptions are the default options of tiller calls
+var DefaultCallOptions = CallOptions{
+	Timeouts: DefaultTimeouts,
+	Retry: RetryOptions{
+		Attempts:   3,
+		Backoff:    250 * time.Millisecond,
+		MaxBackoff: 2 * time.Second,
+	},
+	Breaker: BreakerOptions{
+		Threshold: 5,
+		Cooldown:  30 * time.Second,
+	},
+}
+
+// backoff returns the jittered wait before the retry following the attempt, counting from 0
+func (ro RetryOptions) backoff(attempt int) time.Duration {
+	backoff := ro.Backoff << uint(attempt)
+	if backoff > ro.MaxBackoff || backoff <= 0 {
+		backoff = ro.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	// half of the backoff is fixed and half is random
+	half := int64(backoff / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// CircuitOpenError is returned, without calling tiller, while the circuit breaker is open
+type CircuitOpenError struct {
+	Address    string
+	RetryAfter time.Duration
+}
+
+// Error returns the address of the unavailable tiller
+func (coe *CircuitOpenError) Error() string {
+	return fmt.Sprintf("tiller at %s is unavailable, retry in %v", coe.Address, coe.RetryAfter)
+}
+
+// circuitBreaker stops calling a tiller known to be down. Once open, calls fail immediately until
+// the cooldown ends. A single call is then let through: the circuit closes if tiller answers and
+// opens again if it is still unavailable.
+type circuitBreaker struct {
+	address   string
+	options   BreakerOptions
+	mutex     sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// newCircuitBreaker creates a closed circuit breaker, or nil if it is disabled
+func newCircuitBreaker(address string, options BreakerOptions) *circuitBreaker {
+	if options.Threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{address: address, options: options}
+}
+
+// allow checks if a call can be made, returning a CircuitOpenError if not. probe is set if the
+// call is the single one let through once the cooldown ended, and must be passed to record.
+func (cb *circuitBreaker) allow() (probe bool, err error) {
+	if cb == nil {
+		return false, nil
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if cb.failures < cb.options.Threshold {
+		return false, nil
+	}
+	if wait := time.Until(cb.openUntil); wait > 0 {
+		return false, &CircuitOpenError{Address: cb.address, RetryAfter: wait}
+	}
+	// another call is already probing tiller
+	if cb.probing {
+		return false, &CircuitOpenError{Address: cb.address, RetryAfter: time.Second}
+	}
+	cb.probing = true
+	return true, nil
+}
+
+// record updates the circuit with the result of a call. probe is the one returned by allow for
+// the call, so calls started before the circuit opened don't end the probe.
+func (cb *circuitBreaker) record(probe bool, err error) {
+	if cb == nil {
+		return
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if probe {
+		cb.probing = false
+	}
+	switch {
+	case isUnavailable(err):
+		cb.failures++
+		if cb.failures >= cb.options.Threshold {
+			if !probe {
+				log.Warnf("tiller at %s is unavailable, failing calls for %v", cb.address, cb.options.Cooldown)
+			}
+			cb.openUntil = time.Now().Add(cb.options.Cooldown)
+		}
+	case isCanceled(err):
+		// the caller gave up, tiller's state is unknown
+	default:
+		if cb.failures >= cb.options.Threshold {
+			log.Infof("tiller at %s is available again", cb.address)
+		}
+		cb.failures = 0
+	}
+}
+
+// isUnavailable checks if the call failed because tiller couldn't be reached
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	st, ok := status.FromError(util.RootCause(err))
+	return ok && st.Code() == codes.Unavailable
+}
+
+// isCanceled checks if the call failed because its context was cancelled
+func isCanceled(err error) bool {
+	if err == nil {
+		return false
+	}
+	cause := util.RootCause(err)
+	if cause == context.Canceled {
+		return true
+	}
+	st, ok := status.FromError(cause)
+	return ok && st.Code() == codes.Canceled
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "connection refused")
+
+// openBreaker returns a circuit breaker opened by threshold failed calls
+func openBreaker(t *testing.T, threshold int) *circuitBreaker {
+	cb := newCircuitBreaker("tiller", BreakerOptions{Threshold: threshold, Cooldown: time.Minute})
+	for i := 0; i < threshold; i++ {
+		probe, err := cb.allow()
+		if err != nil {
+			t.Fatalf("call %d: circuit open before the threshold: %v", i, err)
+		}
+		cb.record(probe, errUnavailable)
+	}
+	return cb
+}
+
+// endCooldown makes the open circuit breaker let a probe through
+func endCooldown(cb *circuitBreaker) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.openUntil = time.Now().Add(-time.Millisecond)
+}
+
+// isOpen checks if the error is a CircuitOpenError
+func isOpen(err error) bool {
+	_, open := err.(*CircuitOpenError)
+	return open
+}
+
+// allowed returns the error of allow, for calls that are never recorded
+func allowed(cb *circuitBreaker) error {
+	_, err := cb.allow()
+	return err
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	cb := openBreaker(t, 3)
+	err := allowed(cb)
+	if !isOpen(err) {
+		t.Fatalf("err = %v, want a CircuitOpenError", err)
+	}
+	coe := err.(*CircuitOpenError)
+	if coe.Address != "tiller" || coe.RetryAfter <= 0 || coe.RetryAfter > time.Minute {
+		t.Errorf("err = %+v, want a retry within the cooldown", coe)
+	}
+}
+
+func TestCircuitBreakerCountsConsecutiveFailures(t *testing.T) {
+	cb := newCircuitBreaker("tiller", BreakerOptions{Threshold: 2, Cooldown: time.Minute})
+	tests := []struct {
+		name string
+		err  error
+		open bool
+	}{
+		{name: "first failure", err: errUnavailable},
+		{name: "tiller answered", err: errors.New(`release: "web" not found`)},
+		{name: "first failure after tiller answered", err: errUnavailable},
+		{name: "cancelled call", err: context.Canceled},
+		{name: "cancelled rpc", err: status.Error(codes.Canceled, "context canceled")},
+		{name: "second consecutive failure", err: errUnavailable, open: true},
+	}
+	for _, test := range tests {
+		probe, err := cb.allow()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		cb.record(probe, test.err)
+		if open := isOpen(allowed(cb)); open != test.open {
+			t.Errorf("%s: open = %v, want %v", test.name, open, test.open)
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	cb := openBreaker(t, 2)
+	endCooldown(cb)
+
+	// a single call probes tiller, the others still fail
+	probe, err := cb.allow()
+	if err != nil || !probe {
+		t.Fatalf("probe: probe = %v, err = %v, want a probe", probe, err)
+	}
+	err = allowed(cb)
+	if !isOpen(err) {
+		t.Fatalf("call during the probe: err = %v, want a CircuitOpenError", err)
+	}
+	if retryAfter := err.(*CircuitOpenError).RetryAfter; retryAfter != time.Second {
+		t.Errorf("call during the probe: retry after %v, want 1s", retryAfter)
+	}
+
+	// a failed probe opens the circuit for another cooldown
+	cb.record(probe, errUnavailable)
+	err = allowed(cb)
+	if !isOpen(err) || err.(*CircuitOpenError).RetryAfter <= time.Second {
+		t.Fatalf("after a failed probe: err = %v, want the circuit open for the cooldown", err)
+	}
+
+	// a cancelled probe lets the next call probe
+	endCooldown(cb)
+	if probe, err = cb.allow(); err != nil {
+		t.Fatalf("second probe: unexpected error: %v", err)
+	}
+	cb.record(probe, context.Canceled)
+	if probe, err = cb.allow(); err != nil {
+		t.Fatalf("probe after a cancelled probe: unexpected error: %v", err)
+	}
+
+	// a successful probe closes the circuit
+	cb.record(probe, nil)
+	for i := 0; i < 3; i++ {
+		if probe, err := cb.allow(); err != nil || probe {
+			t.Fatalf("call %d after a successful probe: probe = %v, err = %v", i, probe, err)
+		}
+	}
+	// and the threshold applies again
+	cb.record(false, errUnavailable)
+	if err := allowed(cb); err != nil {
+		t.Errorf("first failure after closing: unexpected error: %v", err)
+	}
+	cb.record(false, errUnavailable)
+	if err := allowed(cb); !isOpen(err) {
+		t.Errorf("threshold after closing: err = %v, want a CircuitOpenError", err)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	cb := newCircuitBreaker("tiller", BreakerOptions{Threshold: 1, Cooldown: time.Minute})
+	// a slow call starts while the circuit is closed
+	slow, err := cb.allow()
+	if err != nil {
+		t.Fatalf("slow call: unexpected error: %v", err)
+	}
+	probe, err := cb.allow()
+	if err != nil {
+		t.Fatalf("failed call: unexpected error: %v", err)
+	}
+	cb.record(probe, errUnavailable)
+	endCooldown(cb)
+	if probe, err = cb.allow(); err != nil || !probe {
+		t.Fatalf("probe: probe = %v, err = %v, want a probe", probe, err)
+	}
+
+	// the slow call ending during the probe doesn't let another probe through
+	cb.record(slow, context.Canceled)
+	if err := allowed(cb); !isOpen(err) {
+		t.Errorf("call during the probe: err = %v, want a CircuitOpenError", err)
+	}
+	cb.record(probe, nil)
+	if err := allowed(cb); err != nil {
+		t.Errorf("after a successful probe: unexpected error: %v", err)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	cb := newCircuitBreaker("tiller", BreakerOptions{Threshold: 0, Cooldown: time.Minute})
+	if cb != nil {
+		t.Fatal("expected a disabled circuit breaker")
+	}
+	for i := 0; i < 10; i++ {
+		cb.record(false, errUnavailable)
+		if err := allowed(cb); err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	ro := RetryOptions{Attempts: 5, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{70, time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 20; i++ {
+			if backoff := ro.backoff(test.attempt); backoff < test.max/2 || backoff > test.max {
+				t.Errorf("attempt %d: backoff = %v, want between %v and %v", test.attempt, backoff, test.max/2, test.max)
+			}
+		}
+	}
+	if backoff := (RetryOptions{}).backoff(0); backoff != 0 {
+		t.Errorf("backoff without options = %v, want 0", backoff)
+	}
+}
@@ -0,0 +1,535 @@
+// Package fake provides an in-memory tiller for testing rudder without a cluster.
+package fake
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"k8s.io/helm/pkg/proto/hapi/release"
+	tiller "k8s.io/helm/pkg/proto/hapi/services"
+	hapi_version "k8s.io/helm/pkg/proto/hapi/version"
+	"k8s.io/helm/pkg/timeconv"
+	helm_version "k8s.io/helm/pkg/version"
+
+	"github.com/AcalephStorage/rudder/internal/client"
+)
+
+// RPC names used for programming errors
+const (
+	ListReleases      = "ListReleases"
+	GetReleaseStatus  = "GetReleaseStatus"
+	GetReleaseContent = "GetReleaseContent"
+	UpdateRelease     = "UpdateRelease"
+	InstallRelease    = "InstallRelease"
+	UninstallRelease  = "UninstallRelease"
+	GetVersion        = "GetVersion"
+	RollbackRelease   = "RollbackRelease"
+	GetHistory        = "GetHistory"
+	RunReleaseTest    = "RunReleaseTest"
+)
+
+const bufferSize = 1024 * 1024
+
+// TillerServer is an in-memory tiller release service served over a bufconn listener. Releases
+// are stored as revisions but charts are not rendered, so manifests are whatever the releases
+// were added with. Releases are copied in and out, so callers never share them with the server.
+// Errors are returned with the same messages as tiller.
+type TillerServer struct {
+	mutex       sync.Mutex
+	revisions   map[string][]*release.Release
+	errors      map[string]error
+	calls       map[string]int
+	latency     time.Duration
+	version     *hapi_version.Version
+	testResults []*tiller.TestReleaseResponse
+	nameCount   int
+
+	listener *bufconn.Listener
+	server   *grpc.Server
+}
+
+// NewTillerServer creates a new, empty TillerServer reporting the version of the helm library
+func NewTillerServer() *TillerServer {
+	return &TillerServer{
+		revisions: make(map[string][]*release.Release),
+		errors:    make(map[string]error),
+		calls:     make(map[string]int),
+		version:   helm_version.GetVersionProto(),
+	}
+}
+
+// Start serves the release service on a new bufconn listener
+func (ts *TillerServer) Start() {
+	ts.listener = bufconn.Listen(bufferSize)
+	ts.server = grpc.NewServer()
+	tiller.RegisterReleaseServiceServer(ts.server, ts)
+	go ts.server.Serve(ts.listener)
+}
+
+// Stop stops serving and closes the listener
+func (ts *TillerServer) Stop() {
+	ts.server.Stop()
+}
+
+// Dialer returns the dialer for connecting to the server with grpc.WithDialer
+func (ts *TillerServer) Dialer() func(string, time.Duration) (net.Conn, error) {
+	return func(string, time.Duration) (net.Conn, error) {
+		return ts.listener.Dial()
+	}
+}
+
+// NewClient returns a TillerClient connected to the server
+func (ts *TillerServer) NewClient() (*client.TillerClient, error) {
+	return ts.NewClientWithOptions(client.DefaultCallOptions)
+}
+
+// NewClientWithOptions returns a TillerClient connected to the server, with the deadlines,
+// retries and circuit breaker of callOptions
+func (ts *TillerServer) NewClientWithOptions(callOptions client.CallOptions) (*client.TillerClient, error) {
+	return client.NewTillerClient("bufconn", nil, callOptions, grpc.WithDialer(ts.Dialer()))
+}
+
+// AddRelease adds a revision of a release. Revisions should be added in order.
+func (ts *TillerServer) AddRelease(rel *release.Release) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.revisions[rel.Name] = append(ts.revisions[rel.Name], clone(rel))
+}
+
+// Revisions returns all the revisions of a release, oldest first
+func (ts *TillerServer) Revisions(name string) []*release.Release {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	return cloneAll(ts.revisions[name])
+}
+
+// SetError makes the RPC fail with err. A nil err clears it.
+func (ts *TillerServer) SetError(rpc string, err error) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	if err == nil {
+		delete(ts.errors, rpc)
+		return
+	}
+	ts.errors[rpc] = err
+}
+
+// Calls returns the number of times the RPC was called
+func (ts *TillerServer) Calls(rpc string) int {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	return ts.calls[rpc]
+}
+
+// SetLatency delays every RPC by latency, or until the call is cancelled
+func (ts *TillerServer) SetLatency(latency time.Duration) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.latency = latency
+}
+
+// SetVersion sets the version reported by GetVersion
+func (ts *TillerServer) SetVersion(semVer string) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.version = &hapi_version.Version{SemVer: semVer}
+}
+
+// SetTestResults sets the messages streamed back by RunReleaseTest
+func (ts *TillerServer) SetTestResults(results []*tiller.TestReleaseResponse) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.testResults = results
+}
+
+// before counts the call, applies the latency and returns the programmed error of the RPC
+func (ts *TillerServer) before(ctx context.Context, rpc string) error {
+	ts.mutex.Lock()
+	ts.calls[rpc]++
+	latency := ts.latency
+	err := ts.errors[rpc]
+	ts.mutex.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// ListReleases lists the latest revision of the releases matching the request. Only deployed
+// releases are listed if no status codes are requested.
+func (ts *TillerServer) ListReleases(req *tiller.ListReleasesRequest, stream tiller.ReleaseService_ListReleasesServer) error {
+	if err := ts.before(stream.Context(), ListReleases); err != nil {
+		return err
+	}
+	var filter *regexp.Regexp
+	if req.Filter != "" {
+		var err error
+		if filter, err = regexp.Compile(req.Filter); err != nil {
+			return err
+		}
+	}
+	statusCodes := req.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = []release.Status_Code{release.Status_DEPLOYED}
+	}
+
+	ts.mutex.Lock()
+	var releases []*release.Release
+	for _, revisions := range ts.revisions {
+		rel := revisions[len(revisions)-1]
+		if req.Namespace != "" && rel.Namespace != req.Namespace {
+			continue
+		}
+		if filter != nil && !filter.MatchString(rel.Name) {
+			continue
+		}
+		if !hasStatus(rel, statusCodes) {
+			continue
+		}
+		releases = append(releases, clone(rel))
+	}
+	ts.mutex.Unlock()
+
+	sort.Slice(releases, func(i, j int) bool {
+		if req.SortOrder == tiller.ListSort_DESC {
+			return releases[i].Name > releases[j].Name
+		}
+		return releases[i].Name < releases[j].Name
+	})
+	total := int64(len(releases))
+	if req.Offset != "" {
+		for i, rel := range releases {
+			if rel.Name == req.Offset {
+				releases = releases[i:]
+				break
+			}
+		}
+	}
+	next := ""
+	if req.Limit > 0 && int64(len(releases)) > req.Limit {
+		next = releases[req.Limit].Name
+		releases = releases[:req.Limit]
+	}
+	return stream.Send(&tiller.ListReleasesResponse{
+		Count:    int64(len(releases)),
+		Next:     next,
+		Total:    total,
+		Releases: releases,
+	})
+}
+
+// GetReleaseStatus returns the status of a revision. version 0 is the latest revision.
+func (ts *TillerServer) GetReleaseStatus(ctx context.Context, req *tiller.GetReleaseStatusRequest) (*tiller.GetReleaseStatusResponse, error) {
+	if err := ts.before(ctx, GetReleaseStatus); err != nil {
+		return nil, err
+	}
+	rel, err := ts.revision(req.Name, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	rel = clone(rel)
+	return &tiller.GetReleaseStatusResponse{
+		Name:      rel.Name,
+		Info:      rel.Info,
+		Namespace: rel.Namespace,
+	}, nil
+}
+
+// GetReleaseContent returns a revision. version 0 is the deployed revision, as in tiller.
+func (ts *TillerServer) GetReleaseContent(ctx context.Context, req *tiller.GetReleaseContentRequest) (*tiller.GetReleaseContentResponse, error) {
+	if err := ts.before(ctx, GetReleaseContent); err != nil {
+		return nil, err
+	}
+	var rel *release.Release
+	var err error
+	if req.Version <= 0 {
+		rel, err = ts.deployed(req.Name)
+	} else {
+		rel, err = ts.revision(req.Name, req.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tiller.GetReleaseContentResponse{Release: clone(rel)}, nil
+}
+
+// UpdateRelease adds a deployed revision of the release, superseding the previous one
+func (ts *TillerServer) UpdateRelease(ctx context.Context, req *tiller.UpdateReleaseRequest) (*tiller.UpdateReleaseResponse, error) {
+	if err := ts.before(ctx, UpdateRelease); err != nil {
+		return nil, err
+	}
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	revisions, found := ts.revisions[req.Name]
+	if !found {
+		return nil, releaseNotFound(req.Name)
+	}
+	current := revisions[len(revisions)-1]
+	config := req.Values
+	if req.ReuseValues {
+		config = current.Config
+	}
+	rel := newRelease(req.Name, current.Namespace, current.Version+1, "Upgrade complete")
+	rel.Chart = req.Chart
+	rel.Config = config
+	if req.DryRun {
+		rel.Info.Description = "Dry run complete"
+		return &tiller.UpdateReleaseResponse{Release: rel}, nil
+	}
+	ts.supersede(req.Name)
+	ts.revisions[req.Name] = append(revisions, rel)
+	return &tiller.UpdateReleaseResponse{Release: clone(rel)}, nil
+}
+
+// InstallRelease adds the first deployed revision of a release. Names of deleted releases can be
+// reused with ReuseName, and a name is generated if none is given.
+func (ts *TillerServer) InstallRelease(ctx context.Context, req *tiller.InstallReleaseRequest) (*tiller.InstallReleaseResponse, error) {
+	if err := ts.before(ctx, InstallRelease); err != nil {
+		return nil, err
+	}
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	name := req.Name
+	if name == "" {
+		ts.nameCount++
+		name = fmt.Sprintf("fake-release-%d", ts.nameCount)
+	}
+	var revision int32 = 1
+	if revisions, found := ts.revisions[name]; found {
+		current := revisions[len(revisions)-1]
+		if !req.ReuseName || current.Info.Status.Code != release.Status_DELETED {
+			return nil, fmt.Errorf("a release named %s already exists.\nRun: helm ls --all %s; to check the status of the release\nOr run: helm del --purge %s; to delete it", name, name, name)
+		}
+		revision = current.Version + 1
+	}
+	namespace := req.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	rel := newRelease(name, namespace, revision, "Install complete")
+	rel.Chart = req.Chart
+	rel.Config = req.Values
+	if req.DryRun {
+		rel.Info.Description = "Dry run complete"
+		return &tiller.InstallReleaseResponse{Release: rel}, nil
+	}
+	ts.revisions[name] = append(ts.revisions[name], rel)
+	return &tiller.InstallReleaseResponse{Release: clone(rel)}, nil
+}
+
+// UninstallRelease marks the release as deleted, or removes it entirely if purged
+func (ts *TillerServer) UninstallRelease(ctx context.Context, req *tiller.UninstallReleaseRequest) (*tiller.UninstallReleaseResponse, error) {
+	if err := ts.before(ctx, UninstallRelease); err != nil {
+		return nil, err
+	}
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	revisions, found := ts.revisions[req.Name]
+	if !found {
+		return nil, releaseNotFound(req.Name)
+	}
+	rel := revisions[len(revisions)-1]
+	rel.Info.Status.Code = release.Status_DELETED
+	rel.Info.Deleted = timeconv.Now()
+	rel.Info.Description = "Deletion complete"
+	if req.Purge {
+		delete(ts.revisions, req.Name)
+	}
+	return &tiller.UninstallReleaseResponse{Release: clone(rel)}, nil
+}
+
+// GetVersion returns the programmed version
+func (ts *TillerServer) GetVersion(ctx context.Context, req *tiller.GetVersionRequest) (*tiller.GetVersionResponse, error) {
+	if err := ts.before(ctx, GetVersion); err != nil {
+		return nil, err
+	}
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	return &tiller.GetVersionResponse{Version: ts.version}, nil
+}
+
+// RollbackRelease adds a deployed revision copying the chart and values of the requested
+// revision. version 0 is the revision before the latest.
+func (ts *TillerServer) RollbackRelease(ctx context.Context, req *tiller.RollbackReleaseRequest) (*tiller.RollbackReleaseResponse, error) {
+	if err := ts.before(ctx, RollbackRelease); err != nil {
+		return nil, err
+	}
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	revisions, found := ts.revisions[req.Name]
+	if !found {
+		return nil, releaseNotFound(req.Name)
+	}
+	current := revisions[len(revisions)-1]
+	version := req.Version
+	if version == 0 {
+		version = current.Version - 1
+	}
+	target := findRevision(revisions, version)
+	if target == nil {
+		return nil, fmt.Errorf("release: %q not found", fmt.Sprintf("%s.v%d", req.Name, version))
+	}
+	rel := newRelease(req.Name, current.Namespace, current.Version+1, fmt.Sprintf("Rollback to %d", version))
+	rel.Chart = target.Chart
+	rel.Config = target.Config
+	rel.Manifest = target.Manifest
+	rel.Hooks = target.Hooks
+	if req.DryRun {
+		return &tiller.RollbackReleaseResponse{Release: rel}, nil
+	}
+	ts.supersede(req.Name)
+	ts.revisions[req.Name] = append(revisions, rel)
+	return &tiller.RollbackReleaseResponse{Release: clone(rel)}, nil
+}
+
+// GetHistory returns the revisions of a release, newest first
+func (ts *TillerServer) GetHistory(ctx context.Context, req *tiller.GetHistoryRequest) (*tiller.GetHistoryResponse, error) {
+	if err := ts.before(ctx, GetHistory); err != nil {
+		return nil, err
+	}
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	revisions, found := ts.revisions[req.Name]
+	if !found {
+		return nil, releaseNotFound(req.Name)
+	}
+	var history []*release.Release
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if req.Max > 0 && int32(len(history)) >= req.Max {
+			break
+		}
+		history = append(history, clone(revisions[i]))
+	}
+	return &tiller.GetHistoryResponse{Releases: history}, nil
+}
+
+// RunReleaseTest streams the programmed test results
+func (ts *TillerServer) RunReleaseTest(req *tiller.TestReleaseRequest, stream tiller.ReleaseService_RunReleaseTestServer) error {
+	if err := ts.before(stream.Context(), RunReleaseTest); err != nil {
+		return err
+	}
+	if _, err := ts.revision(req.Name, 0); err != nil {
+		return err
+	}
+	ts.mutex.Lock()
+	results := ts.testResults
+	ts.mutex.Unlock()
+	for _, result := range results {
+		if err := stream.Send(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revision returns a revision of the release. version 0 is the latest revision.
+func (ts *TillerServer) revision(name string, version int32) (*release.Release, error) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	revisions, found := ts.revisions[name]
+	if !found {
+		return nil, releaseNotFound(name)
+	}
+	if version == 0 {
+		return revisions[len(revisions)-1], nil
+	}
+	rel := findRevision(revisions, version)
+	if rel == nil {
+		return nil, fmt.Errorf("release: %q not found", fmt.Sprintf("%s.v%d", name, version))
+	}
+	return rel, nil
+}
+
+// deployed returns the latest deployed revision of the release
+func (ts *TillerServer) deployed(name string) (*release.Release, error) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	revisions := ts.revisions[name]
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].GetInfo().GetStatus().GetCode() == release.Status_DEPLOYED {
+			return revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%q has no deployed releases", name)
+}
+
+// supersede marks the deployed revisions of the release as superseded
+func (ts *TillerServer) supersede(name string) {
+	for _, rel := range ts.revisions[name] {
+		if rel.Info.Status.Code == release.Status_DEPLOYED {
+			rel.Info.Status.Code = release.Status_SUPERSEDED
+		}
+	}
+}
+
+// NewRelease returns a deployed release revision, for adding with AddRelease
+func NewRelease(name, namespace string, revision int32) *release.Release {
+	return newRelease(name, namespace, revision, "Install complete")
+}
+
+// newRelease returns a deployed release revision
+func newRelease(name, namespace string, revision int32, description string) *release.Release {
+	now := timeconv.Now()
+	return &release.Release{
+		Name:      name,
+		Namespace: namespace,
+		Version:   revision,
+		Info: &release.Info{
+			Status:        &release.Status{Code: release.Status_DEPLOYED},
+			FirstDeployed: now,
+			LastDeployed:  now,
+			Description:   description,
+		},
+	}
+}
+
+// clone returns a deep copy of the release
+func clone(rel *release.Release) *release.Release {
+	return proto.Clone(rel).(*release.Release)
+}
+
+// cloneAll returns deep copies of the releases
+func cloneAll(releases []*release.Release) []*release.Release {
+	clones := make([]*release.Release, len(releases))
+	for i, rel := range releases {
+		clones[i] = clone(rel)
+	}
+	return clones
+}
+
+// findRevision returns the revision with the version, or nil
+func findRevision(revisions []*release.Release, version int32) *release.Release {
+	for _, rel := range revisions {
+		if rel.Version == version {
+			return rel
+		}
+	}
+	return nil
+}
+
+// hasStatus checks if the release has one of the status codes
+func hasStatus(rel *release.Release, statusCodes []release.Status_Code) bool {
+	for _, code := range statusCodes {
+		if rel.GetInfo().GetStatus().GetCode() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseNotFound returns tiller's error for a missing release
+func releaseNotFound(name string) error {
+	return fmt.Errorf("release: %q not found", name)
+}
@@ -0,0 +1,78 @@
+package fake
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"k8s.io/helm/pkg/proto/hapi/release"
+	tiller "k8s.io/helm/pkg/proto/hapi/services"
+)
+
+func TestGetReleaseContent(t *testing.T) {
+	ts := NewTillerServer()
+	ts.AddRelease(NewRelease("web", "default", 1))
+	deployed := NewRelease("web", "default", 2)
+	deployed.Manifest = "deployed"
+	ts.AddRelease(deployed)
+	failed := NewRelease("web", "default", 3)
+	failed.Info.Status.Code = release.Status_FAILED
+	ts.AddRelease(failed)
+	undeployed := NewRelease("db", "default", 1)
+	undeployed.Info.Status.Code = release.Status_FAILED
+	ts.AddRelease(undeployed)
+
+	tests := []struct {
+		name    string
+		release string
+		version int32
+		want    int32
+		err     string
+	}{
+		{name: "version 0 is the deployed revision", release: "web", want: 2},
+		{name: "a given revision", release: "web", version: 3, want: 3},
+		{name: "missing revision", release: "web", version: 4, err: `release: "web.v4" not found`},
+		{name: "no deployed revision", release: "db", err: `"db" has no deployed releases`},
+		{name: "missing release", release: "cache", err: `"cache" has no deployed releases`},
+	}
+	for _, test := range tests {
+		res, err := ts.GetReleaseContent(context.Background(), &tiller.GetReleaseContentRequest{Name: test.release, Version: test.version})
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: err = %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if res.Release.Version != test.want {
+			t.Errorf("%s: revision = %d, want %d", test.name, res.Release.Version, test.want)
+		}
+	}
+}
+
+func TestReleasesAreCopied(t *testing.T) {
+	ts := NewTillerServer()
+	added := NewRelease("web", "default", 1)
+	ts.AddRelease(added)
+
+	res, err := ts.UninstallRelease(context.Background(), &tiller.UninstallReleaseRequest{Name: "web"})
+	if err != nil {
+		t.Fatalf("unable to uninstall: %v", err)
+	}
+	if code := added.Info.Status.Code; code != release.Status_DEPLOYED {
+		t.Errorf("added release status = %s, want it untouched", code)
+	}
+	if code := res.Release.Info.Status.Code; code != release.Status_DELETED {
+		t.Errorf("uninstalled release status = %s, want %s", code, release.Status_DELETED)
+	}
+
+	res.Release.Info.Status.Code = release.Status_DEPLOYED
+	ts.Revisions("web")[0].Namespace = "other"
+	stored := ts.Revisions("web")[0]
+	if stored.Info.Status.Code != release.Status_DELETED || stored.Namespace != "default" {
+		t.Errorf("stored release = %v, want it untouched", stored)
+	}
+}
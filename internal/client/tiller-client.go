@@ -1,104 +1,355 @@
 package client
 
 import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"crypto/tls"
+
 	log "github.com/Sirupsen/logrus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	tiller "k8s.io/helm/pkg/proto/hapi/services"
 	"k8s.io/helm/pkg/version"
+
+	"github.com/AcalephStorage/rudder/internal/util"
 )
 
-// TillerClient is a wrapper for accessing Tiller's gRPC
-type TillerClient struct {
-	address string
-	context context.Context
+// tiller errors. the errors returned by TillerClient wrap these if tiller failed for that reason,
+// so they can be checked using util.IsError.
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+	ErrReleaseExists   = errors.New("release already exists")
+)
+
+// keepaliveTime is the ping interval of idle connections. tiller closes connections pinging more
+// often than every 20s, so this is the same as helm's.
+const keepaliveTime = 30 * time.Second
+
+// kubeTimeoutMargin is added to the kubernetes timeout of a request, to leave tiller time to
+// respond after waiting that long
+const kubeTimeoutMargin = 30 * time.Second
+
+// Timeouts are the deadlines of tiller calls. Read is used for listing and getting releases and
+// for the version, Write for install, upgrade, rollback and uninstall, and Test for release tests.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	Test  time.Duration
+}
+
+// DefaultTimeouts are the default deadlines of tiller calls
+var DefaultTimeouts = Timeouts{
+	Read:  30 * time.Second,
+	Write: 10 * time.Minute,
+	Test:  10 * time.Minute,
 }
 
-// NewTillerClient creates a new TillerClient instance
-func NewTillerClient(address string) *TillerClient {
-	md := metadata.Pairs("x-helm-api-client", version.Version)
-	ctx := metadata.NewOutgoingContext(context.TODO(), md)
-	return &TillerClient{address: address, context: ctx}
+// ConnectionState is the state of the connection to tiller
+type ConnectionState struct {
+	State string    `json:"state"`
+	Ready bool      `json:"ready"`
+	Since time.Time `json:"since"`
 }
 
-func (tc *TillerClient) execute(request func(tiller.ReleaseServiceClient)) error {
-	conn, err := grpc.Dial(tc.address, grpc.WithInsecure())
+// TillerClient is a wrapper for accessing Tiller's gRPC. A single connection is shared by all
+// requests, and is re-established automatically if tiller becomes unreachable. Every call takes
+// the context of the request it is made for, so it is cancelled if the request is. Read calls are
+// retried while tiller is unavailable, mutating calls never are.
+type TillerClient struct {
+	address  string
+	timeouts Timeouts
+	retry    RetryOptions
+	breaker  *circuitBreaker
+	conn     *grpc.ClientConn
+	mutex    sync.RWMutex
+	state    connectivity.State
+	since    time.Time
+}
+
+// NewTillerClient creates a new TillerClient instance and starts connecting to tiller. tlsConfig
+// may be nil to connect without TLS. opts are added to the dial options, eg. to dial a fake tiller.
+func NewTillerClient(address string, tlsConfig *tls.Config, callOptions CallOptions, opts ...grpc.DialOption) (*TillerClient, error) {
+	transport := grpc.WithInsecure()
+	if tlsConfig != nil {
+		transport = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+	dialOpts := []grpc.DialOption{
+		transport,
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: keepaliveTime}),
+	}
+	conn, err := grpc.Dial(address, append(dialOpts, opts...)...)
 	if err != nil {
 		log.Debug("unable to dial tiller")
-		return err
+		return nil, err
+	}
+	tc := &TillerClient{
+		address:  address,
+		timeouts: callOptions.Timeouts,
+		retry:    callOptions.Retry,
+		breaker:  newCircuitBreaker(address, callOptions.Breaker),
+		conn:     conn,
+		state:    conn.GetState(),
+		since:    time.Now(),
 	}
-	defer conn.Close()
-	rsc := tiller.NewReleaseServiceClient(conn)
-	request(rsc)
-	return nil
+	go tc.watchState()
+	return tc, nil
 }
 
-// ListReleases returns a list of release from tiller
-func (tc *TillerClient) ListReleases(req *tiller.ListReleasesRequest) (res *tiller.ListReleasesResponse, err error) {
-	log.Info(req)
-	tc.execute(func(rsc tiller.ReleaseServiceClient) {
-		lrc, err := rsc.ListReleases(tc.context, req)
+// State returns the current state of the connection to tiller
+func (tc *TillerClient) State() ConnectionState {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+	return ConnectionState{
+		State: tc.state.String(),
+		Ready: tc.state == connectivity.Ready,
+		Since: tc.since,
+	}
+}
+
+// Close closes the connection to tiller
+func (tc *TillerClient) Close() error {
+	return tc.conn.Close()
+}
+
+// watchState tracks the connection state until the connection is closed
+func (tc *TillerClient) watchState() {
+	state := tc.conn.GetState()
+	for state != connectivity.Shutdown {
+		tc.conn.WaitForStateChange(context.Background(), state)
+		newState := tc.conn.GetState()
+		if newState == connectivity.TransientFailure {
+			log.Warnf("tiller at %s is unreachable, reconnecting...", tc.address)
+		} else {
+			log.Debugf("tiller connection to %s is %s", tc.address, newState)
+		}
+		tc.mutex.Lock()
+		tc.state = newState
+		tc.since = time.Now()
+		tc.mutex.Unlock()
+		state = newState
+	}
+}
+
+// execute runs the request against tiller with the timeout and the helm client version set on
+// the context. The timeout covers the retries too. Only idempotent requests should be retried.
+// The error of the request is returned, wrapped with the matching tiller error if there is one.
+func (tc *TillerClient) execute(ctx context.Context, timeout time.Duration, retry bool, request func(context.Context, tiller.ReleaseServiceClient) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs("x-helm-api-client", version.Version))
+	rsc := tiller.NewReleaseServiceClient(tc.conn)
+	attempts := 1
+	if retry && tc.retry.Attempts > 1 {
+		attempts = tc.retry.Attempts
+	}
+	for attempt := 0; ; attempt++ {
+		probe, err := tc.breaker.allow()
+		if err != nil {
+			return err
+		}
+		err = request(ctx, rsc)
+		tc.breaker.record(probe, err)
+		if err == nil {
+			return nil
+		}
+		if attempt+1 >= attempts || !isUnavailable(err) {
+			return tillerError(err)
+		}
+		backoff := tc.retry.backoff(attempt)
+		log.WithError(err).Debugf("tiller at %s is unavailable, retrying in %v", tc.address, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return tillerError(err)
+		}
+	}
+}
+
+// withKubeTimeout returns the timeout, extended if tiller may wait longer than that for
+// kubernetes. kubeTimeout is in seconds, as in the tiller requests.
+func withKubeTimeout(timeout time.Duration, kubeTimeout int64) time.Duration {
+	if wait := time.Duration(kubeTimeout)*time.Second + kubeTimeoutMargin; wait > timeout {
+		return wait
+	}
+	return timeout
+}
+
+// tillerError wraps the error with the tiller error matching its message. tiller returns most
+// errors without a gRPC status code, so the message is all there is.
+func tillerError(err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "release: ") && strings.Contains(msg, "not found"),
+		strings.Contains(msg, "no revision for release"),
+		strings.Contains(msg, "has no deployed releases"):
+		return util.WrapError(ErrReleaseNotFound, err)
+	case strings.Contains(msg, "cannot re-use a name that is still in use"),
+		strings.Contains(msg, "a release named") && strings.Contains(msg, "already exists"):
+		return util.WrapError(ErrReleaseExists, err)
+	}
+	return err
+}
+
+// ListReleases returns a list of release from tiller. Tiller may split the list into several
+// messages, these are all read and merged into a single response.
+func (tc *TillerClient) ListReleases(ctx context.Context, req *tiller.ListReleasesRequest) (*tiller.ListReleasesResponse, error) {
+	log.Debug(req)
+	res := &tiller.ListReleasesResponse{}
+	err := tc.execute(ctx, tc.timeouts.Read, true, func(ctx context.Context, rsc tiller.ReleaseServiceClient) error {
+		// a retried list starts over
+		*res = tiller.ListReleasesResponse{}
+		lrc, err := rsc.ListReleases(ctx, req)
 		if err != nil {
 			log.Debug("unable to list all releases")
-			return
+			return err
+		}
+		for {
+			chunk, err := lrc.Recv()
+			if err == io.EOF {
+				// every message carries the count of the whole list
+				res.Count = int64(len(res.Releases))
+				return nil
+			}
+			if err != nil {
+				log.Debug("unable to receive list of releases")
+				return err
+			}
+			res.Releases = append(res.Releases, chunk.Releases...)
+			res.Next = chunk.Next
+			res.Total = chunk.Total
 		}
-		res, err = lrc.Recv()
 	})
-	return
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
 // InstallRelease installs a new release
-func (tc *TillerClient) InstallRelease(req *tiller.InstallReleaseRequest) (res *tiller.InstallReleaseResponse, err error) {
-	tc.execute(func(rsc tiller.ReleaseServiceClient) {
-		res, err = rsc.InstallRelease(tc.context, req)
+func (tc *TillerClient) InstallRelease(ctx context.Context, req *tiller.InstallReleaseRequest) (res *tiller.InstallReleaseResponse, err error) {
+	err = tc.execute(ctx, withKubeTimeout(tc.timeouts.Write, req.Timeout), false, func(ctx context.Context, rsc tiller.ReleaseServiceClient) (err error) {
+		res, err = rsc.InstallRelease(ctx, req)
 		if err != nil {
 			log.Debug("unable to install release")
 		}
+		return
 	})
 	return
 }
 
 // UpdateRelease updates an existing release
-func (tc *TillerClient) UpdateRelease(req *tiller.UpdateReleaseRequest) (res *tiller.UpdateReleaseResponse, err error) {
-	tc.execute(func(rsc tiller.ReleaseServiceClient) {
-		res, err = rsc.UpdateRelease(tc.context, req)
+func (tc *TillerClient) UpdateRelease(ctx context.Context, req *tiller.UpdateReleaseRequest) (res *tiller.UpdateReleaseResponse, err error) {
+	err = tc.execute(ctx, withKubeTimeout(tc.timeouts.Write, req.Timeout), false, func(ctx context.Context, rsc tiller.ReleaseServiceClient) (err error) {
+		res, err = rsc.UpdateRelease(ctx, req)
 		if err != nil {
-			log.Debug("unable to install release")
+			log.Debug("unable to update release")
 		}
+		return
 	})
 	return
 }
 
 // UninstallRelease uninstalls a release
-func (tc *TillerClient) UninstallRelease(req *tiller.UninstallReleaseRequest) (res *tiller.UninstallReleaseResponse, err error) {
-	tc.execute(func(rsc tiller.ReleaseServiceClient) {
-		res, err = rsc.UninstallRelease(tc.context, req)
+func (tc *TillerClient) UninstallRelease(ctx context.Context, req *tiller.UninstallReleaseRequest) (res *tiller.UninstallReleaseResponse, err error) {
+	err = tc.execute(ctx, withKubeTimeout(tc.timeouts.Write, req.Timeout), false, func(ctx context.Context, rsc tiller.ReleaseServiceClient) (err error) {
+		res, err = rsc.UninstallRelease(ctx, req)
 		if err != nil {
 			log.Debug("unable to uninstall release")
 		}
+		return
 	})
 	return
 }
 
 // GetReleaseContent returns the contents of a release
-func (tc *TillerClient) GetReleaseContent(req *tiller.GetReleaseContentRequest) (res *tiller.GetReleaseContentResponse, err error) {
-	tc.execute(func(rsc tiller.ReleaseServiceClient) {
-		res, err = rsc.GetReleaseContent(tc.context, req)
+func (tc *TillerClient) GetReleaseContent(ctx context.Context, req *tiller.GetReleaseContentRequest) (res *tiller.GetReleaseContentResponse, err error) {
+	err = tc.execute(ctx, tc.timeouts.Read, true, func(ctx context.Context, rsc tiller.ReleaseServiceClient) (err error) {
+		res, err = rsc.GetReleaseContent(ctx, req)
 		if err != nil {
 			log.Debug("unable to get release content")
 		}
+		return
 	})
 	return
 }
 
 // GetReleaseStatus returns the status of a release
-func (tc *TillerClient) GetReleaseStatus(req *tiller.GetReleaseStatusRequest) (res *tiller.GetReleaseStatusResponse, err error) {
-	tc.execute(func(rsc tiller.ReleaseServiceClient) {
-		res, err = rsc.GetReleaseStatus(tc.context, req)
+func (tc *TillerClient) GetReleaseStatus(ctx context.Context, req *tiller.GetReleaseStatusRequest) (res *tiller.GetReleaseStatusResponse, err error) {
+	err = tc.execute(ctx, tc.timeouts.Read, true, func(ctx context.Context, rsc tiller.ReleaseServiceClient) (err error) {
+		res, err = rsc.GetReleaseStatus(ctx, req)
 		if err != nil {
 			log.Debug("unable to get release status")
 		}
+		return
+	})
+	return
+}
+
+// RollbackRelease rolls back a release to a previous version
+func (tc *TillerClient) RollbackRelease(ctx context.Context, req *tiller.RollbackReleaseRequest) (res *tiller.RollbackReleaseResponse, err error) {
+	err = tc.execute(ctx, withKubeTimeout(tc.timeouts.Write, req.Timeout), false, func(ctx context.Context, rsc tiller.ReleaseServiceClient) (err error) {
+		res, err = rsc.RollbackRelease(ctx, req)
+		if err != nil {
+			log.Debug("unable to rollback release")
+		}
+		return
+	})
+	return
+}
+
+// GetHistory returns the revision history of a release
+func (tc *TillerClient) GetHistory(ctx context.Context, req *tiller.GetHistoryRequest) (res *tiller.GetHistoryResponse, err error) {
+	err = tc.execute(ctx, tc.timeouts.Read, true, func(ctx context.Context, rsc tiller.ReleaseServiceClient) (err error) {
+		res, err = rsc.GetHistory(ctx, req)
+		if err != nil {
+			log.Debug("unable to get release history")
+		}
+		return
+	})
+	return
+}
+
+// RunReleaseTest runs the tests of a release. handle is called for every message streamed back
+// by tiller, and an error returned by handle stops the tests.
+func (tc *TillerClient) RunReleaseTest(ctx context.Context, req *tiller.TestReleaseRequest, handle func(*tiller.TestReleaseResponse) error) error {
+	return tc.execute(ctx, withKubeTimeout(tc.timeouts.Test, req.Timeout), false, func(ctx context.Context, rsc tiller.ReleaseServiceClient) error {
+		rtc, err := rsc.RunReleaseTest(ctx, req)
+		if err != nil {
+			log.Debug("unable to run release test")
+			return err
+		}
+		for {
+			res, err := rtc.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				log.Debug("unable to receive release test result")
+				return err
+			}
+			if err := handle(res); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// GetVersion returns the version of tiller
+func (tc *TillerClient) GetVersion(ctx context.Context) (res *tiller.GetVersionResponse, err error) {
+	err = tc.execute(ctx, tc.timeouts.Read, true, func(ctx context.Context, rsc tiller.ReleaseServiceClient) (err error) {
+		res, err = rsc.GetVersion(ctx, &tiller.GetVersionRequest{})
+		if err != nil {
+			log.Debug("unable to get tiller version")
+		}
+		return
 	})
 	return
 }
@@ -0,0 +1,182 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	tiller "k8s.io/helm/pkg/proto/hapi/services"
+
+	"github.com/AcalephStorage/rudder/internal/client"
+	"github.com/AcalephStorage/rudder/internal/client/fake"
+)
+
+// newTestClient starts a fake tiller and returns a client connected to it with the call options.
+// stop closes the connection and stops the fake tiller.
+func newTestClient(t *testing.T, callOptions client.CallOptions) (ts *fake.TillerServer, tc *client.TillerClient, stop func()) {
+	ts = fake.NewTillerServer()
+	ts.Start()
+	tc, err := ts.NewClientWithOptions(callOptions)
+	if err != nil {
+		ts.Stop()
+		t.Fatalf("unable to connect to the fake tiller: %v", err)
+	}
+	stop = func() {
+		tc.Close()
+		ts.Stop()
+	}
+	return ts, tc, stop
+}
+
+// statusCode returns the gRPC status code of the error
+func statusCode(err error) codes.Code {
+	st, _ := status.FromError(err)
+	return st.Code()
+}
+
+func TestReadDeadline(t *testing.T) {
+	ts, tc, stop := newTestClient(t, client.CallOptions{
+		Timeouts: client.Timeouts{Read: 50 * time.Millisecond, Write: 50 * time.Millisecond, Test: time.Minute},
+	})
+	defer stop()
+	ts.AddRelease(fake.NewRelease("web", "default", 1))
+	ts.SetLatency(500 * time.Millisecond)
+
+	start := time.Now()
+	_, err := tc.GetReleaseStatus(context.Background(), &tiller.GetReleaseStatusRequest{Name: "web"})
+	if code := statusCode(err); code != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want %s", err, codes.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Errorf("the call took %v, longer than the tiller latency", elapsed)
+	}
+}
+
+func TestWriteDeadlineCoversKubeTimeout(t *testing.T) {
+	ts, tc, stop := newTestClient(t, client.CallOptions{
+		Timeouts: client.Timeouts{Read: 50 * time.Millisecond, Write: 50 * time.Millisecond, Test: time.Minute},
+	})
+	defer stop()
+	ts.SetLatency(200 * time.Millisecond)
+
+	// the write deadline is extended to the kubernetes timeout of the request, plus a margin
+	res, err := tc.InstallRelease(context.Background(), &tiller.InstallReleaseRequest{Name: "web", Timeout: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Release.Name != "web" {
+		t.Errorf("installed %s, want web", res.Release.Name)
+	}
+}
+
+func TestCancelledRequest(t *testing.T) {
+	ts, tc, stop := newTestClient(t, client.DefaultCallOptions)
+	defer stop()
+	ts.AddRelease(fake.NewRelease("web", "default", 1))
+	ts.SetLatency(time.Minute)
+
+	// the request is cancelled, as if the HTTP client went away, while tiller is still working
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := tc.GetReleaseContent(ctx, &tiller.GetReleaseContentRequest{Name: "web"})
+	if code := statusCode(err); code != codes.Canceled {
+		t.Errorf("err = %v, want %s", err, codes.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("the call took %v after being cancelled", elapsed)
+	}
+}
+
+// retryOptions retry three times without waiting long, with the circuit breaker disabled
+var retryOptions = client.CallOptions{
+	Timeouts: client.DefaultTimeouts,
+	Retry:    client.RetryOptions{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+}
+
+func TestReadCallsAreRetried(t *testing.T) {
+	ts, tc, stop := newTestClient(t, retryOptions)
+	defer stop()
+	ts.AddRelease(fake.NewRelease("web", "default", 1))
+	unavailable := status.Error(codes.Unavailable, "tiller is restarting")
+
+	calls := []struct {
+		rpc  string
+		call func() error
+	}{
+		{fake.ListReleases, func() error {
+			_, err := tc.ListReleases(context.Background(), &tiller.ListReleasesRequest{})
+			return err
+		}},
+		{fake.GetReleaseStatus, func() error {
+			_, err := tc.GetReleaseStatus(context.Background(), &tiller.GetReleaseStatusRequest{Name: "web"})
+			return err
+		}},
+		{fake.GetReleaseContent, func() error {
+			_, err := tc.GetReleaseContent(context.Background(), &tiller.GetReleaseContentRequest{Name: "web"})
+			return err
+		}},
+		{fake.GetHistory, func() error {
+			_, err := tc.GetHistory(context.Background(), &tiller.GetHistoryRequest{Name: "web"})
+			return err
+		}},
+	}
+	for _, test := range calls {
+		ts.SetError(test.rpc, unavailable)
+		if code := statusCode(test.call()); code != codes.Unavailable {
+			t.Errorf("%s: code = %s, want %s", test.rpc, code, codes.Unavailable)
+		}
+		if n := ts.Calls(test.rpc); n != retryOptions.Retry.Attempts {
+			t.Errorf("%s: called %d times, want %d", test.rpc, n, retryOptions.Retry.Attempts)
+		}
+		ts.SetError(test.rpc, nil)
+	}
+
+	// other errors are not retried
+	if _, err := tc.GetReleaseStatus(context.Background(), &tiller.GetReleaseStatusRequest{Name: "missing"}); err == nil {
+		t.Error("status of a missing release: expected an error")
+	}
+	if n, want := ts.Calls(fake.GetReleaseStatus), retryOptions.Retry.Attempts+1; n != want {
+		t.Errorf("status of a missing release: called %d times in total, want %d", n, want)
+	}
+}
+
+func TestMutatingCallsAreNotRetried(t *testing.T) {
+	ts, tc, stop := newTestClient(t, retryOptions)
+	defer stop()
+	ts.AddRelease(fake.NewRelease("web", "default", 1))
+	unavailable := status.Error(codes.Unavailable, "tiller is restarting")
+
+	calls := []struct {
+		rpc  string
+		call func() error
+	}{
+		{fake.InstallRelease, func() error {
+			_, err := tc.InstallRelease(context.Background(), &tiller.InstallReleaseRequest{Name: "db"})
+			return err
+		}},
+		{fake.UpdateRelease, func() error {
+			_, err := tc.UpdateRelease(context.Background(), &tiller.UpdateReleaseRequest{Name: "web"})
+			return err
+		}},
+		{fake.UninstallRelease, func() error {
+			_, err := tc.UninstallRelease(context.Background(), &tiller.UninstallReleaseRequest{Name: "web"})
+			return err
+		}},
+		{fake.RollbackRelease, func() error {
+			_, err := tc.RollbackRelease(context.Background(), &tiller.RollbackReleaseRequest{Name: "web"})
+			return err
+		}},
+	}
+	for _, test := range calls {
+		ts.SetError(test.rpc, unavailable)
+		if code := statusCode(test.call()); code != codes.Unavailable {
+			t.Errorf("%s: code = %s, want %s", test.rpc, code, codes.Unavailable)
+		}
+		if n := ts.Calls(test.rpc); n != 1 {
+			t.Errorf("%s: called %d times, want once", test.rpc, n)
+		}
+	}
+}
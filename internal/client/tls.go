@@ -0,0 +1,52 @@
+package client
+
+import (
+	"errors"
+
+	"crypto/tls"
+
+	"k8s.io/helm/pkg/tlsutil"
+)
+
+// TLSOptions are the TLS settings of the connection to tiller. Verify implies Enable. ServerName
+// overrides the name the tiller certificate is verified against, which is the tiller host by default.
+type TLSOptions struct {
+	Enable     bool   `json:"tls"`
+	Verify     bool   `json:"tls_verify"`
+	CertFile   string `json:"tls_cert"`
+	KeyFile    string `json:"tls_key"`
+	CACertFile string `json:"tls_ca_cert"`
+	ServerName string `json:"tls_hostname"`
+}
+
+// Enabled checks if tiller should be connected to using TLS
+func (opts *TLSOptions) Enabled() bool {
+	return opts.Enable || opts.Verify
+}
+
+// Config returns the TLS configuration the same way helm does it. The CA cert is only used if
+// Verify is set, otherwise the tiller certificate is not verified. Returns nil if TLS is disabled.
+func (opts *TLSOptions) Config() (*tls.Config, error) {
+	if !opts.Enabled() {
+		return nil, nil
+	}
+	// tiller requires a client certificate when TLS is enabled
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("tls cert and key are required when tls is enabled")
+	}
+	tlsOpts := tlsutil.Options{
+		CertFile:           opts.CertFile,
+		KeyFile:            opts.KeyFile,
+		InsecureSkipVerify: true,
+	}
+	if opts.Verify {
+		tlsOpts.CaCertFile = opts.CACertFile
+		tlsOpts.InsecureSkipVerify = false
+	}
+	tlsConfig, err := tlsutil.ClientConfig(tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ServerName = opts.ServerName
+	return tlsConfig, nil
+}
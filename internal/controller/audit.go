@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// audit outcomes
+const (
+	AuditSuccess  = "success"
+	AuditFailure  = "failure"
+	AuditAccepted = "accepted"
+)
+
+const (
+	maxMemoryAuditRecords = 10000
+	// the audit file is read backwards in blocks
+	auditBlockSize   = 64 * 1024
+	maxAuditLineSize = 1024 * 1024
+)
+
+var errAuditRecordTooLong = errors.New("audit record too long")
+
+// AuditRecord is a single mutating API call. Cluster is empty for calls to the default cluster.
+// Async calls are recorded twice: once accepted, then with the outcome of their operation.
+type AuditRecord struct {
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"`
+	Groups      []string  `json:"groups,omitempty"`
+	SourceIP    string    `json:"source_ip"`
+	Operation   string    `json:"operation"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Release     string    `json:"release,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Chart       string    `json:"chart,omitempty"`
+	Version     string    `json:"version,omitempty"`
+	ValuesHash  string    `json:"values_hash,omitempty"`
+	StatusCode  int       `json:"status_code"`
+	OperationID string    `json:"operation_id,omitempty"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error,omitempty"`
+	Duration    string    `json:"duration"`
+}
+
+// AuditQuery filters audit records. Zero values match everything.
+type AuditQuery struct {
+	Since   time.Time
+	Until   time.Time
+	User    string
+	Release string
+	Limit   int
+}
+
+// matches checks if the record passes the query filters
+func (q *AuditQuery) matches(record *AuditRecord) bool {
+	if !q.Since.IsZero() && record.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && record.Time.After(q.Until) {
+		return false
+	}
+	if q.User != "" && record.User != q.User {
+		return false
+	}
+	if q.Release != "" && record.Release != q.Release {
+		return false
+	}
+	return true
+}
+
+// AuditSink receives every audit record
+type AuditSink interface {
+	Write(record *AuditRecord) error
+}
+
+// AuditReader is a sink that can be queried
+type AuditReader interface {
+	AuditSink
+	Query(query AuditQuery) ([]AuditRecord, error)
+}
+
+// AuditController writes audit records to the configured sinks. Queries are served by the reader.
+type AuditController struct {
+	sinks  []AuditSink
+	reader AuditReader
+}
+
+// NewAuditController creates a new AuditController. reader is written to as well, so it should
+// not be repeated in sinks.
+func NewAuditController(reader AuditReader, sinks ...AuditSink) *AuditController {
+	return &AuditController{
+		sinks:  append([]AuditSink{reader}, sinks...),
+		reader: reader,
+	}
+}
+
+// Record writes the record to all sinks. Failing sinks are logged but don't fail the request.
+func (ac *AuditController) Record(record *AuditRecord) {
+	for _, sink := range ac.sinks {
+		if err := sink.Write(record); err != nil {
+			log.WithError(err).Errorf("unable to write audit record of %s by %s", record.Operation, record.User)
+		}
+	}
+}
+
+// Query returns the matching records, newest first
+func (ac *AuditController) Query(query AuditQuery) ([]AuditRecord, error) {
+	return ac.reader.Query(query)
+}
+
+// ValuesHash returns the sha256 of the values. map keys are sorted by encoding/json so equal
+// values always have the same hash.
+func ValuesHash(values interface{}) string {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryAuditSink keeps the most recent audit records in memory
+type MemoryAuditSink struct {
+	mutex   sync.RWMutex
+	records []AuditRecord
+}
+
+// NewMemoryAuditSink creates a new MemoryAuditSink
+func NewMemoryAuditSink() *MemoryAuditSink {
+	return &MemoryAuditSink{}
+}
+
+// Write adds the record, dropping the oldest ones if full
+func (ms *MemoryAuditSink) Write(record *AuditRecord) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.records = append(ms.records, *record)
+	if len(ms.records) > maxMemoryAuditRecords {
+		ms.records = ms.records[len(ms.records)-maxMemoryAuditRecords:]
+	}
+	return nil
+}
+
+// Query returns the matching records, newest first
+func (ms *MemoryAuditSink) Query(query AuditQuery) ([]AuditRecord, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return filterAuditRecords(ms.records, query), nil
+}
+
+// FileAuditSink appends audit records as JSON lines to a file
+type FileAuditSink struct {
+	path  string
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileAuditSink opens, or creates, the audit file for appending
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{path: path, file: file}, nil
+}
+
+// Write appends the record to the file
+func (fs *FileAuditSink) Write(record *AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	_, err = fs.file.Write(append(line, '\n'))
+	return err
+}
+
+// Query reads the file backwards from its end and returns the matching records, newest first. It
+// stops reading once Limit records matched. The file is read through its own handle so writes
+// aren't blocked. Lines that can't be parsed, like a record still being written, are skipped.
+func (fs *FileAuditSink) Query(query AuditQuery) ([]AuditRecord, error) {
+	file, err := os.Open(fs.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]AuditRecord, 0)
+	err = readLinesBackwards(file, info.Size(), func(line []byte) bool {
+		var record AuditRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			log.WithError(err).Warn("skipping malformed audit record")
+			return true
+		}
+		if query.matches(&record) {
+			matched = append(matched, record)
+		}
+		return query.Limit <= 0 || len(matched) < query.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+// Reopen closes the file and opens, or creates, it again at the same path. It is called after the
+// file has been moved away by log rotation.
+func (fs *FileAuditSink) Reopen() error {
+	file, err := os.OpenFile(fs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.file.Close()
+	fs.file = file
+	return nil
+}
+
+// readLinesBackwards calls fn with the non empty lines of the first size bytes of the reader, last
+// line first, until fn returns false
+func readLinesBackwards(reader io.ReaderAt, size int64, fn func(line []byte) bool) error {
+	// partial is the start of a line continuing in the blocks already read
+	var partial []byte
+	for offset := size; offset > 0; {
+		n := int64(auditBlockSize)
+		if n > offset {
+			n = offset
+		}
+		offset -= n
+		block := make([]byte, n, n+int64(len(partial)))
+		if _, err := reader.ReadAt(block, offset); err != nil {
+			return err
+		}
+		block = append(block, partial...)
+		for i := bytes.LastIndexByte(block, '\n'); i >= 0; i = bytes.LastIndexByte(block, '\n') {
+			if line := block[i+1:]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+			block = block[:i]
+		}
+		if len(block) > maxAuditLineSize {
+			return errAuditRecordTooLong
+		}
+		partial = block
+	}
+	if len(partial) > 0 {
+		fn(partial)
+	}
+	return nil
+}
+
+// StdoutAuditSink writes audit records as JSON lines to stdout
+type StdoutAuditSink struct {
+	mutex sync.Mutex
+}
+
+// NewStdoutAuditSink creates a new StdoutAuditSink
+func NewStdoutAuditSink() *StdoutAuditSink {
+	return &StdoutAuditSink{}
+}
+
+// Write prints the record to stdout
+func (ss *StdoutAuditSink) Write(record *AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	_, err = os.Stdout.Write(append(line, '\n'))
+	return err
+}
+
+// filterAuditRecords returns the records matching the query, newest first
+func filterAuditRecords(records []AuditRecord, query AuditQuery) []AuditRecord {
+	matched := make([]AuditRecord, 0)
+	for i := len(records) - 1; i >= 0; i-- {
+		if !query.matches(&records[i]) {
+			continue
+		}
+		matched = append(matched, records[i])
+		if query.Limit > 0 && len(matched) >= query.Limit {
+			break
+		}
+	}
+	return matched
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"io/ioutil"
+	"path/filepath"
+)
+
+func TestFileAuditSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rudder-audit")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("unable to open audit log: %v", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		record := &AuditRecord{
+			Time:    start.Add(time.Duration(i) * time.Minute),
+			User:    fmt.Sprintf("user-%d", i%2),
+			Release: "web",
+			Outcome: AuditSuccess,
+		}
+		if err := sink.Write(record); err != nil {
+			t.Fatalf("unable to write record: %v", err)
+		}
+	}
+	// a record still being written is skipped
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("unable to open audit log: %v", err)
+	}
+	f.WriteString(`{"user":"partial`)
+	f.Close()
+
+	records, err := sink.Query(AuditQuery{})
+	if err != nil {
+		t.Fatalf("unable to query: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("got %d records, want 5", len(records))
+	}
+	if !records[0].Time.After(records[4].Time) {
+		t.Error("records are not newest first")
+	}
+
+	records, err = sink.Query(AuditQuery{User: "user-1", Since: start.Add(2 * time.Minute), Limit: 1})
+	if err != nil {
+		t.Fatalf("unable to query: %v", err)
+	}
+	if len(records) != 1 || records[0].User != "user-1" || !records[0].Time.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("records = %+v, want the newest record of user-1", records)
+	}
+}
+
+func TestFileAuditSinkQueryDoesNotBlockWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rudder-audit")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sink, err := NewFileAuditSink(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatalf("unable to open audit log: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if _, err := sink.Query(AuditQuery{}); err != nil {
+				t.Errorf("unable to query: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := sink.Write(&AuditRecord{Time: time.Now(), User: "user"}); err != nil {
+			t.Fatalf("unable to write record: %v", err)
+		}
+	}
+	<-done
+}
+
+func TestFileAuditSinkQueryReadsBackwards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rudder-audit")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sink, err := NewFileAuditSink(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatalf("unable to open audit log: %v", err)
+	}
+
+	// enough records to span several blocks
+	start := time.Now()
+	count := 3 * auditBlockSize / 100
+	for i := 0; i < count; i++ {
+		record := &AuditRecord{
+			Time:    start.Add(time.Duration(i) * time.Second),
+			User:    fmt.Sprintf("user-%d", i%3),
+			Release: fmt.Sprintf("release-%d", i),
+			Outcome: AuditSuccess,
+		}
+		if err := sink.Write(record); err != nil {
+			t.Fatalf("unable to write record: %v", err)
+		}
+	}
+
+	records, err := sink.Query(AuditQuery{})
+	if err != nil {
+		t.Fatalf("unable to query: %v", err)
+	}
+	if len(records) != count {
+		t.Fatalf("got %d records, want %d", len(records), count)
+	}
+	for i, record := range records {
+		if want := fmt.Sprintf("release-%d", count-1-i); record.Release != want {
+			t.Fatalf("record %d is %s, want %s", i, record.Release, want)
+		}
+	}
+
+	records, err = sink.Query(AuditQuery{User: "user-0", Limit: 2})
+	if err != nil {
+		t.Fatalf("unable to query: %v", err)
+	}
+	newest := count - 1 - (count-1)%3
+	if len(records) != 2 || records[0].Release != fmt.Sprintf("release-%d", newest) || records[1].Release != fmt.Sprintf("release-%d", newest-3) {
+		t.Errorf("records = %+v, want the two newest records of user-0", records)
+	}
+}
+
+func TestFileAuditSinkReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rudder-audit")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("unable to open audit log: %v", err)
+	}
+	if err := sink.Write(&AuditRecord{Time: time.Now(), User: "before"}); err != nil {
+		t.Fatalf("unable to write record: %v", err)
+	}
+
+	// rotate the file
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("unable to move audit log: %v", err)
+	}
+	if err := sink.Reopen(); err != nil {
+		t.Fatalf("unable to reopen audit log: %v", err)
+	}
+	if err := sink.Write(&AuditRecord{Time: time.Now(), User: "after"}); err != nil {
+		t.Fatalf("unable to write record: %v", err)
+	}
+
+	records, err := sink.Query(AuditQuery{})
+	if err != nil {
+		t.Fatalf("unable to query: %v", err)
+	}
+	if len(records) != 1 || records[0].User != "after" {
+		t.Errorf("records = %+v, want only the record written after reopening", records)
+	}
+}
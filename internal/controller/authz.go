@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// authorization verbs
+const (
+	VerbList     = "list"
+	VerbGet      = "get"
+	VerbInstall  = "install"
+	VerbUpgrade  = "upgrade"
+	VerbRollback = "rollback"
+	VerbDelete   = "delete"
+	VerbAdmin    = "admin"
+)
+
+// wildcard matches any user, group, verb, cluster, namespace or repo
+const wildcard = "*"
+
+// Policy allows its users and groups the verbs on the matching clusters, namespaces and repos.
+// Clusters, namespaces and repos are glob patterns (eg. team-*). An empty Clusters, Namespaces or
+// Repos list matches all of them.
+type Policy struct {
+	Name       string   `json:"name"`
+	Users      []string `json:"users"`
+	Groups     []string `json:"groups"`
+	Verbs      []string `json:"verbs"`
+	Clusters   []string `json:"clusters"`
+	Namespaces []string `json:"namespaces"`
+	Repos      []string `json:"repos"`
+}
+
+// PolicyConfig is the authorization policy file
+type PolicyConfig struct {
+	Policies []*Policy `json:"policies"`
+}
+
+// AccessRequest is an action to be authorized. Cluster and Repo are only checked if set. Namespace
+// is only checked if Namespaced is set, and an empty Namespace then means all namespaces.
+type AccessRequest struct {
+	User       string
+	Groups     []string
+	Verb       string
+	Cluster    string
+	Namespaced bool
+	Namespace  string
+	Repo       string
+}
+
+// String describes the request for denial reasons
+func (ar *AccessRequest) String() string {
+	desc := fmt.Sprintf("user %s", ar.User)
+	if len(ar.Groups) > 0 {
+		desc += fmt.Sprintf(" (groups: %s)", strings.Join(ar.Groups, ", "))
+	}
+	desc += fmt.Sprintf(" is not allowed to %s", ar.Verb)
+	if ar.Namespaced {
+		if ar.Namespace == "" {
+			desc += " in all namespaces"
+		} else {
+			desc += fmt.Sprintf(" in namespace %s", ar.Namespace)
+		}
+	}
+	if ar.Repo != "" {
+		desc += fmt.Sprintf(" using repo %s", ar.Repo)
+	}
+	if ar.Cluster != "" {
+		desc += fmt.Sprintf(" on cluster %s", ar.Cluster)
+	}
+	return desc
+}
+
+// AuthzController authorizes requests against the policies. Anything not allowed by a policy is denied.
+type AuthzController struct {
+	policies []*Policy
+}
+
+// NewAuthzController creates a new AuthzController. Invalid verbs and patterns are rejected.
+func NewAuthzController(policies []*Policy) (*AuthzController, error) {
+	for _, policy := range policies {
+		if err := policy.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &AuthzController{policies: policies}, nil
+}
+
+// Authorize returns an error with the reason if no policy allows the request
+func (ac *AuthzController) Authorize(req *AccessRequest) error {
+	for _, policy := range ac.policies {
+		if policy.allows(req) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s", req)
+}
+
+// validate checks the verbs and patterns of the policy
+func (p *Policy) validate() error {
+	for _, verb := range p.Verbs {
+		switch verb {
+		case wildcard, VerbList, VerbGet, VerbInstall, VerbUpgrade, VerbRollback, VerbDelete, VerbAdmin:
+		default:
+			return fmt.Errorf("policy %s: unknown verb %s", p.Name, verb)
+		}
+	}
+	patterns := append(append(append([]string{}, p.Clusters...), p.Namespaces...), p.Repos...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("policy %s: invalid pattern %s", p.Name, pattern)
+		}
+	}
+	return nil
+}
+
+// allows checks if the policy allows the request
+func (p *Policy) allows(req *AccessRequest) bool {
+	if !p.appliesTo(req.User, req.Groups) {
+		return false
+	}
+	if !contains(p.Verbs, req.Verb) {
+		return false
+	}
+	if req.Cluster != "" && !matchesAny(p.Clusters, req.Cluster) {
+		return false
+	}
+	if req.Namespaced && !matchesAny(p.Namespaces, req.Namespace) {
+		return false
+	}
+	if req.Repo != "" && !matchesAny(p.Repos, req.Repo) {
+		return false
+	}
+	return true
+}
+
+// appliesTo checks if the user or one of the groups is part of the policy
+func (p *Policy) appliesTo(user string, groups []string) bool {
+	if contains(p.Users, user) {
+		return true
+	}
+	for _, group := range groups {
+		if contains(p.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains checks if the value or the wildcard is in the list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == wildcard || item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny checks if the value matches one of the patterns. an empty list matches everything,
+// while an empty value (eg. all namespaces) is only matched by the wildcard.
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pattern == wildcard {
+			return true
+		}
+		if value == "" {
+			continue
+		}
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
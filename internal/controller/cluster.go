@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/AcalephStorage/rudder/internal/client"
+)
+
+// ErrClusterNotFound is returned for clusters that are not configured
+var ErrClusterNotFound = errors.New("cluster not found")
+
+// Cluster is a named tiller backend
+type Cluster struct {
+	client.TLSOptions
+	Name          string `json:"name"`
+	TillerAddress string `json:"tiller_address"`
+}
+
+// ClusterConfig is the clusters configuration file. Default is the cluster used by the routes
+// without a cluster, and defaults to the first cluster.
+type ClusterConfig struct {
+	Default  string     `json:"default"`
+	Clusters []*Cluster `json:"clusters"`
+}
+
+// ClusterInfo is the listed information of a cluster
+type ClusterInfo struct {
+	Name          string                 `json:"name"`
+	TillerAddress string                 `json:"tiller_address"`
+	TLS           bool                   `json:"tls"`
+	Default       bool                   `json:"default"`
+	Connection    client.ConnectionState `json:"connection"`
+}
+
+// TillerBackend is the release service and connection state of the tiller of a cluster
+type TillerBackend interface {
+	client.ReleaseBackend
+	State() client.ConnectionState
+}
+
+// BackendFactory creates the tiller backend of a cluster
+type BackendFactory func(cluster *Cluster) (TillerBackend, error)
+
+// NewTillerBackendFactory returns a BackendFactory dialing the tiller of every cluster. callOptions
+// are the deadlines, retries and circuit breaker of the tiller calls of every cluster.
+func NewTillerBackendFactory(callOptions client.CallOptions, opts ...grpc.DialOption) BackendFactory {
+	return func(cluster *Cluster) (TillerBackend, error) {
+		tlsConfig, err := cluster.Config()
+		if err != nil {
+			return nil, err
+		}
+		return client.NewTillerClient(cluster.TillerAddress, tlsConfig, callOptions, opts...)
+	}
+}
+
+// clusterBackend is the tiller backend, release controller and watcher of a cluster
+type clusterBackend struct {
+	cluster           *Cluster
+	tillerClient      TillerBackend
+	releaseController *ReleaseController
+	watcher           *ReleaseWatcher
+}
+
+// ClusterController holds a release controller and watcher for every cluster
+type ClusterController struct {
+	defaultCluster string
+	names          []string
+	backends       map[string]*clusterBackend
+}
+
+// NewClusterController creates the release controller and watcher of every cluster, with the
+// tiller backend created by newBackend. The tiller version of every cluster is checked, and an
+// incompatible one fails the creation.
+func NewClusterController(config *ClusterConfig, repoController *RepoController, webhookController *WebhookController, watchInterval time.Duration, newBackend BackendFactory) (*ClusterController, error) {
+	if len(config.Clusters) == 0 {
+		return nil, errors.New("no clusters configured")
+	}
+	cc := &ClusterController{
+		defaultCluster: config.Default,
+		backends:       make(map[string]*clusterBackend),
+	}
+	if cc.defaultCluster == "" {
+		cc.defaultCluster = config.Clusters[0].Name
+	}
+	for _, cluster := range config.Clusters {
+		if cluster.Name == "" || cluster.TillerAddress == "" {
+			return nil, errors.New("clusters need a name and a tiller_address")
+		}
+		if _, found := cc.backends[cluster.Name]; found {
+			return nil, fmt.Errorf("duplicate cluster %s", cluster.Name)
+		}
+		tillerClient, err := newBackend(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", cluster.Name, err)
+		}
+		releaseController := NewReleaseController(cluster.Name, tillerClient, repoController, webhookController)
+		if err := releaseController.CheckTillerVersion(context.Background()); err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", cluster.Name, err)
+		}
+		cc.backends[cluster.Name] = &clusterBackend{
+			cluster:           cluster,
+			tillerClient:      tillerClient,
+			releaseController: releaseController,
+			watcher:           NewReleaseWatcher(releaseController, watchInterval),
+		}
+		cc.names = append(cc.names, cluster.Name)
+		log.Infof("cluster %s added with tiller at %s", cluster.Name, cluster.TillerAddress)
+	}
+	if _, found := cc.backends[cc.defaultCluster]; !found {
+		return nil, fmt.Errorf("default cluster %s is not configured", cc.defaultCluster)
+	}
+	sort.Strings(cc.names)
+	return cc, nil
+}
+
+// ListClusters returns the configured clusters sorted by name
+func (cc *ClusterController) ListClusters() []ClusterInfo {
+	clusters := make([]ClusterInfo, len(cc.names))
+	for i, name := range cc.names {
+		backend := cc.backends[name]
+		clusters[i] = ClusterInfo{
+			Name:          backend.cluster.Name,
+			TillerAddress: backend.cluster.TillerAddress,
+			TLS:           backend.cluster.Enabled(),
+			Default:       backend.cluster.Name == cc.defaultCluster,
+			Connection:    backend.tillerClient.State(),
+		}
+	}
+	return clusters
+}
+
+// DefaultCluster returns the name of the cluster used by the routes without a cluster
+func (cc *ClusterController) DefaultCluster() string {
+	return cc.defaultCluster
+}
+
+// Ready checks if the connection to the tiller of the default cluster is ready
+func (cc *ClusterController) Ready() bool {
+	return cc.backends[cc.defaultCluster].tillerClient.State().Ready
+}
+
+// ReleaseController returns the release controller of the cluster. An empty name is the default cluster.
+func (cc *ClusterController) ReleaseController(cluster string) (*ReleaseController, error) {
+	backend, err := cc.backend(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return backend.releaseController, nil
+}
+
+// Watcher returns the release watcher of the cluster. An empty name is the default cluster.
+func (cc *ClusterController) Watcher(cluster string) (*ReleaseWatcher, error) {
+	backend, err := cc.backend(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return backend.watcher, nil
+}
+
+// ReleaseNamespace returns the namespace of a release in the cluster, or an empty string if the
+// release doesn't exist
+func (cc *ClusterController) ReleaseNamespace(ctx context.Context, cluster, name string) (string, error) {
+	rc, err := cc.ReleaseController(cluster)
+	if err != nil {
+		return "", err
+	}
+	return rc.ReleaseNamespace(ctx, name)
+}
+
+// backend returns the backend of the cluster
+func (cc *ClusterController) backend(cluster string) (*clusterBackend, error) {
+	if cluster == "" {
+		cluster = cc.defaultCluster
+	}
+	backend, found := cc.backends[cluster]
+	if !found {
+		return nil, ErrClusterNotFound
+	}
+	return backend, nil
+}
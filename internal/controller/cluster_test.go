@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/AcalephStorage/rudder/internal/client/fake"
+)
+
+// fakeBackends returns a BackendFactory connecting every cluster to its fake tiller
+func fakeBackends(servers map[string]*fake.TillerServer) BackendFactory {
+	return func(cluster *Cluster) (TillerBackend, error) {
+		ts, found := servers[cluster.Name]
+		if !found {
+			return nil, errors.New("no fake tiller")
+		}
+		return ts.NewClient()
+	}
+}
+
+func TestNewClusterController(t *testing.T) {
+	servers := map[string]*fake.TillerServer{
+		"staging":    fake.NewTillerServer(),
+		"production": fake.NewTillerServer(),
+	}
+	for _, ts := range servers {
+		ts.Start()
+		defer ts.Stop()
+	}
+	servers["staging"].AddRelease(fake.NewRelease("web", "team-a", 1))
+	servers["production"].AddRelease(fake.NewRelease("web", "team-b", 1))
+
+	config := &ClusterConfig{
+		Default: "production",
+		Clusters: []*Cluster{
+			{Name: "staging", TillerAddress: "staging:44134"},
+			{Name: "production", TillerAddress: "production:44134"},
+		},
+	}
+	cc, err := NewClusterController(config, nil, nil, time.Minute, fakeBackends(servers))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clusters := cc.ListClusters()
+	if len(clusters) != 2 || clusters[0].Name != "production" || !clusters[0].Default || clusters[1].Name != "staging" || clusters[1].Default {
+		t.Errorf("clusters = %+v, want production as the default followed by staging", clusters)
+	}
+	for cluster, want := range map[string]string{"": "team-b", "production": "team-b", "staging": "team-a"} {
+		namespace, err := cc.ReleaseNamespace(context.Background(), cluster, "web")
+		if err != nil || namespace != want {
+			t.Errorf("namespace of web in cluster %q = %q, %v, want %s", cluster, namespace, err, want)
+		}
+	}
+	if _, err := cc.ReleaseController("missing"); err != ErrClusterNotFound {
+		t.Errorf("err = %v, want %v", err, ErrClusterNotFound)
+	}
+}
+
+func TestNewClusterControllerErrors(t *testing.T) {
+	ts := fake.NewTillerServer()
+	ts.Start()
+	defer ts.Stop()
+	incompatible := fake.NewTillerServer()
+	incompatible.SetVersion("v3.0.0")
+	incompatible.Start()
+	defer incompatible.Stop()
+	servers := map[string]*fake.TillerServer{"a": ts, "b": ts, "old": incompatible}
+
+	tests := []struct {
+		name   string
+		config ClusterConfig
+	}{
+		{name: "no clusters"},
+		{name: "no tiller address", config: ClusterConfig{Clusters: []*Cluster{{Name: "a"}}}},
+		{name: "duplicate cluster", config: ClusterConfig{Clusters: []*Cluster{{Name: "a", TillerAddress: "a"}, {Name: "a", TillerAddress: "b"}}}},
+		{name: "unknown default", config: ClusterConfig{Default: "c", Clusters: []*Cluster{{Name: "a", TillerAddress: "a"}}}},
+		{name: "backend failure", config: ClusterConfig{Clusters: []*Cluster{{Name: "c", TillerAddress: "c"}}}},
+		{name: "incompatible tiller", config: ClusterConfig{Clusters: []*Cluster{{Name: "a", TillerAddress: "a"}, {Name: "old", TillerAddress: "old"}}}},
+	}
+	for _, test := range tests {
+		if _, err := NewClusterController(&test.config, nil, nil, time.Minute, fakeBackends(servers)); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/helm/pkg/releaseutil"
+
+	"github.com/AcalephStorage/rudder/internal/util"
+)
+
+// diff change types
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// KubeObject identifies a kubernetes object of a release
+type KubeObject struct {
+	APIVersion string            `json:"api_version"`
+	Kind       string            `json:"kind"`
+	Namespace  string            `json:"namespace"`
+	Name       string            `json:"name"`
+	Labels     map[string]string `json:"labels"`
+}
+
+// GroupByKind groups the objects by their kind
+func GroupByKind(objects []KubeObject) map[string][]KubeObject {
+	groups := make(map[string][]KubeObject)
+	for _, obj := range objects {
+		groups[obj.Kind] = append(groups[obj.Kind], obj)
+	}
+	return groups
+}
+
+// manifestObject is a single kubernetes object of a release manifest
+type manifestObject struct {
+	KubeObject
+	Content string
+}
+
+// key uniquely identifies the object within a release
+func (mo *manifestObject) key() string {
+	return fmt.Sprintf("%s/%s/%s", mo.Namespace, mo.Kind, mo.Name)
+}
+
+// manifestHeader is the part of a kubernetes object needed to identify it
+type manifestHeader struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"metadata"`
+}
+
+// ManifestDiff contains the differences of a single kubernetes object between two manifests
+type ManifestDiff struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Change    string `json:"change"`
+	Diff      string `json:"diff"`
+}
+
+// parseManifest splits a release manifest into its kubernetes objects. Objects without a
+// namespace are assigned the release namespace. The objects are sorted by kind, namespace and name.
+func parseManifest(manifest, namespace string) ([]*manifestObject, error) {
+	var objects []*manifestObject
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var header manifestHeader
+		if err := yaml.Unmarshal([]byte(doc), &header); err != nil {
+			return nil, err
+		}
+		// skip empty documents
+		if header.Kind == "" {
+			continue
+		}
+		obj := &manifestObject{
+			KubeObject: KubeObject{
+				APIVersion: header.APIVersion,
+				Kind:       header.Kind,
+				Namespace:  header.Metadata.Namespace,
+				Name:       header.Metadata.Name,
+				Labels:     header.Metadata.Labels,
+			},
+			// trailing whitespace depends on where the object is in the manifest
+			Content: strings.TrimRight(doc, " \t\n") + "\n",
+		}
+		if obj.Namespace == "" {
+			obj.Namespace = namespace
+		}
+		objects = append(objects, obj)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Kind != objects[j].Kind {
+			return objects[i].Kind < objects[j].Kind
+		}
+		if objects[i].Namespace != objects[j].Namespace {
+			return objects[i].Namespace < objects[j].Namespace
+		}
+		return objects[i].Name < objects[j].Name
+	})
+	return objects, nil
+}
+
+// diffManifests returns the per object differences between two release manifests
+func diffManifests(from, to, namespace string) ([]ManifestDiff, error) {
+	fromObjects, err := parseManifest(from, namespace)
+	if err != nil {
+		return nil, err
+	}
+	toObjects, err := parseManifest(to, namespace)
+	if err != nil {
+		return nil, err
+	}
+	toMap := make(map[string]*manifestObject)
+	for _, obj := range toObjects {
+		toMap[obj.key()] = obj
+	}
+
+	diffs := make([]ManifestDiff, 0)
+	seen := make(map[string]bool)
+	for _, fromObj := range fromObjects {
+		key := fromObj.key()
+		seen[key] = true
+		toObj, found := toMap[key]
+		if !found {
+			diffs = append(diffs, newManifestDiff(fromObj, ChangeRemoved, fromObj.Content, ""))
+			continue
+		}
+		if fromObj.Content != toObj.Content {
+			diffs = append(diffs, newManifestDiff(fromObj, ChangeChanged, fromObj.Content, toObj.Content))
+		}
+	}
+	for _, toObj := range toObjects {
+		if !seen[toObj.key()] {
+			diffs = append(diffs, newManifestDiff(toObj, ChangeAdded, "", toObj.Content))
+		}
+	}
+	return diffs, nil
+}
+
+// newManifestDiff creates the unified diff of an object
+func newManifestDiff(obj *manifestObject, change, from, to string) ManifestDiff {
+	file := obj.key()
+	return ManifestDiff{
+		Kind:      obj.Kind,
+		Namespace: obj.Namespace,
+		Name:      obj.Name,
+		Change:    change,
+		Diff:      util.UnifiedDiff(file, file, from, to, 3),
+	}
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"testing"
+)
+
+const (
+	testService = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: ClusterIP
+`
+	testServiceChanged = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: NodePort
+`
+	testConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: other
+data:
+  key: value
+`
+)
+
+func TestDiffManifests(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []ManifestDiff
+	}{
+		{
+			name: "empty input",
+			want: []ManifestDiff{},
+		},
+		{
+			name: "no changes",
+			from: testService,
+			to:   testService,
+			want: []ManifestDiff{},
+		},
+		{
+			name: "trailing newlines are ignored",
+			from: testService,
+			to:   testService + "\n\n",
+			want: []ManifestDiff{},
+		},
+		{
+			name: "added object",
+			from: testService,
+			to:   testService + "---\n" + testConfigMap,
+			want: []ManifestDiff{
+				{Kind: "ConfigMap", Namespace: "other", Name: "config", Change: ChangeAdded},
+			},
+		},
+		{
+			name: "removed object",
+			from: testConfigMap + "---\n" + testService,
+			to:   testService,
+			want: []ManifestDiff{
+				{Kind: "ConfigMap", Namespace: "other", Name: "config", Change: ChangeRemoved},
+			},
+		},
+		{
+			name: "changed object",
+			from: testService,
+			to:   testServiceChanged,
+			want: []ManifestDiff{
+				{Kind: "Service", Namespace: "default", Name: "web", Change: ChangeChanged},
+			},
+		},
+		{
+			name: "removed everything",
+			from: testService + "---\n" + testConfigMap,
+			want: []ManifestDiff{
+				{Kind: "ConfigMap", Namespace: "other", Name: "config", Change: ChangeRemoved},
+				{Kind: "Service", Namespace: "default", Name: "web", Change: ChangeRemoved},
+			},
+		},
+	}
+	for _, test := range tests {
+		diffs, err := diffManifests(test.from, test.to, "default")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(diffs) != len(test.want) {
+			t.Errorf("%s: got %d diffs, want %d: %+v", test.name, len(diffs), len(test.want), diffs)
+			continue
+		}
+		for i, diff := range diffs {
+			want := test.want[i]
+			if diff.Kind != want.Kind || diff.Namespace != want.Namespace || diff.Name != want.Name || diff.Change != want.Change {
+				t.Errorf("%s: diff %d = %s %s/%s/%s, want %s %s/%s/%s", test.name, i,
+					diff.Change, diff.Namespace, diff.Kind, diff.Name, want.Change, want.Namespace, want.Kind, want.Name)
+			}
+			if diff.Diff == "" {
+				t.Errorf("%s: diff %d has no unified diff", test.name, i)
+			}
+		}
+	}
+}
+
+func TestDiffManifestsUnifiedDiff(t *testing.T) {
+	diffs, err := diffManifests(testService, testServiceChanged, "default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diffs) != 1 {
+		t.Fatalf("got %d diffs, want 1", len(diffs))
+	}
+	want := "--- default/Service/web\n+++ default/Service/web\n" +
+		"@@ -3,4 +3,4 @@\n metadata:\n   name: web\n spec:\n-  type: ClusterIP\n+  type: NodePort\n"
+	if diffs[0].Diff != want {
+		t.Errorf("diff =\n%q\nwant\n%q", diffs[0].Diff, want)
+	}
+}
+
+func TestDiffManifestsInvalidYAML(t *testing.T) {
+	if _, err := diffManifests("kind: [", testService, "default"); err == nil {
+		t.Error("expected an error for an invalid manifest")
+	}
+}
@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"crypto/rand"
+	"encoding/hex"
+	"runtime/debug"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// operation statuses
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+var (
+	errOperationQueueFull = errors.New("too many pending operations")
+	errOperationNotFound  = errors.New("operation not found")
+)
+
+// Operation is a release operation running in the background. Cluster is empty for operations on
+// the default cluster.
+type Operation struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Cluster   string      `json:"cluster,omitempty"`
+	Namespace string      `json:"namespace"`
+	Release   string      `json:"release"`
+	Status    string      `json:"status"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Created   time.Time   `json:"created"`
+	Started   time.Time   `json:"started"`
+	Finished  time.Time   `json:"finished"`
+}
+
+// operationJob is a queued operation along with the function doing the work
+type operationJob struct {
+	operation *Operation
+	run       func() (interface{}, error)
+}
+
+// OperationController runs release operations in a bounded pool of workers. Finished operations
+// are kept for the retention period.
+type OperationController struct {
+	mutex      sync.RWMutex
+	operations map[string]*Operation
+	listeners  map[string][]func(Operation)
+	queue      chan *operationJob
+	retention  time.Duration
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewOperationController creates a new operation controller and starts its workers
+func NewOperationController(workers, queueSize int, retention time.Duration) *OperationController {
+	oc := &OperationController{
+		operations: make(map[string]*Operation),
+		listeners:  make(map[string][]func(Operation)),
+		queue:      make(chan *operationJob, queueSize),
+		retention:  retention,
+		stop:       make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go oc.work()
+	}
+	go oc.cleanup()
+	return oc
+}
+
+// Submit queues an operation on the release in the cluster and namespace. run is called by one of
+// the workers and its result is stored in the operation.
+func (oc *OperationController) Submit(opType, cluster, namespace, release string, run func() (interface{}, error)) (*Operation, error) {
+	id, err := newID()
+	if err != nil {
+		log.WithError(err).Error("unable to generate operation id")
+		return nil, err
+	}
+	op := &Operation{
+		ID:        id,
+		Type:      opType,
+		Cluster:   cluster,
+		Namespace: namespace,
+		Release:   release,
+		Status:    OperationPending,
+		Created:   time.Now(),
+	}
+
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+	select {
+	case oc.queue <- &operationJob{operation: op, run: run}:
+		oc.operations[id] = op
+	default:
+		log.WithError(errOperationQueueFull).Errorf("unable to queue %s of %s", opType, release)
+		return nil, errOperationQueueFull
+	}
+	snapshot := *op
+	return &snapshot, nil
+}
+
+// Get returns a snapshot of the operation with the given id
+func (oc *OperationController) Get(id string) (*Operation, error) {
+	oc.mutex.RLock()
+	defer oc.mutex.RUnlock()
+	op, found := oc.operations[id]
+	if !found {
+		return nil, errOperationNotFound
+	}
+	snapshot := *op
+	return &snapshot, nil
+}
+
+// OnFinish calls fn with the operation once it has finished, right away if it already has
+func (oc *OperationController) OnFinish(id string, fn func(Operation)) error {
+	oc.mutex.Lock()
+	op, found := oc.operations[id]
+	if !found {
+		oc.mutex.Unlock()
+		return errOperationNotFound
+	}
+	if op.Status == OperationSucceeded || op.Status == OperationFailed {
+		snapshot := *op
+		oc.mutex.Unlock()
+		fn(snapshot)
+		return nil
+	}
+	oc.listeners[id] = append(oc.listeners[id], fn)
+	oc.mutex.Unlock()
+	return nil
+}
+
+// Stop stops the workers and the cleanup of finished operations. Running operations finish, but
+// pending ones are no longer run.
+func (oc *OperationController) Stop() {
+	oc.stopOnce.Do(func() {
+		close(oc.stop)
+	})
+}
+
+// work runs queued operations until the controller is stopped
+func (oc *OperationController) work() {
+	for {
+		select {
+		case <-oc.stop:
+			return
+		case job := <-oc.queue:
+			oc.update(job.operation, func(op *Operation) {
+				op.Status = OperationRunning
+				op.Started = time.Now()
+			})
+			result, err := runJob(job)
+			oc.finish(job.operation, result, err)
+			log.Infof("%s of %s finished", job.operation.Type, job.operation.Release)
+		}
+	}
+}
+
+// finish stores the outcome of the operation and notifies its listeners
+func (oc *OperationController) finish(op *Operation, result interface{}, err error) {
+	oc.mutex.Lock()
+	op.Finished = time.Now()
+	if err != nil {
+		op.Status = OperationFailed
+		op.Error = err.Error()
+	} else {
+		op.Status = OperationSucceeded
+		op.Result = result
+	}
+	snapshot := *op
+	listeners := oc.listeners[op.ID]
+	delete(oc.listeners, op.ID)
+	oc.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(snapshot)
+	}
+}
+
+// runJob runs the job, turning a panic into an error so the worker survives it
+func runJob(job *operationJob) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("%s of %s panicked: %v\n%s", job.operation.Type, job.operation.Release, r, debug.Stack())
+			result = nil
+			err = fmt.Errorf("%s of %s failed unexpectedly: %v", job.operation.Type, job.operation.Release, r)
+		}
+	}()
+	return job.run()
+}
+
+// update modifies the operation while holding the lock
+func (oc *OperationController) update(op *Operation, modify func(*Operation)) {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+	modify(op)
+}
+
+// cleanup periodically removes finished operations older than the retention period, until the
+// controller is stopped
+func (oc *OperationController) cleanup() {
+	interval := oc.retention / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-oc.stop:
+			return
+		case <-ticker.C:
+			oc.removeExpired()
+		}
+	}
+}
+
+// removeExpired removes finished operations older than the retention period
+func (oc *OperationController) removeExpired() {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+	for id, op := range oc.operations {
+		finished := op.Status == OperationSucceeded || op.Status == OperationFailed
+		if finished && time.Since(op.Finished) > oc.retention {
+			delete(oc.operations, id)
+		}
+	}
+}
+
+// newID generates a random id for operations and webhook events
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// waitForOperation polls the operation until it is finished
+func waitForOperation(t *testing.T, oc *OperationController, id string) *Operation {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		op, err := oc.Get(id)
+		if err != nil {
+			t.Fatalf("unable to get operation: %v", err)
+		}
+		if op.Status == OperationSucceeded || op.Status == OperationFailed {
+			return op
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("operation %s did not finish", id)
+	return nil
+}
+
+func TestOperationController(t *testing.T) {
+	oc := NewOperationController(1, 10, time.Minute)
+	defer oc.Stop()
+
+	succeeded, err := oc.Submit("install", "", "default", "web", func() (interface{}, error) {
+		return "done", nil
+	})
+	if err != nil {
+		t.Fatalf("unable to submit: %v", err)
+	}
+	if succeeded.Status != OperationPending {
+		t.Errorf("status = %s, want %s", succeeded.Status, OperationPending)
+	}
+	failed, err := oc.Submit("upgrade", "", "default", "web", func() (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	if err != nil {
+		t.Fatalf("unable to submit: %v", err)
+	}
+	panicked, err := oc.Submit("delete", "", "default", "web", func() (interface{}, error) {
+		panic("oops")
+	})
+	if err != nil {
+		t.Fatalf("unable to submit: %v", err)
+	}
+	// the worker must survive the panic to run this one
+	after, err := oc.Submit("rollback", "", "default", "web", func() (interface{}, error) {
+		return "after", nil
+	})
+	if err != nil {
+		t.Fatalf("unable to submit: %v", err)
+	}
+
+	if op := waitForOperation(t, oc, succeeded.ID); op.Status != OperationSucceeded || op.Result != "done" {
+		t.Errorf("succeeded operation = %+v", op)
+	}
+	if op := waitForOperation(t, oc, failed.ID); op.Status != OperationFailed || op.Error != "boom" {
+		t.Errorf("failed operation = %+v", op)
+	}
+	if op := waitForOperation(t, oc, panicked.ID); op.Status != OperationFailed || op.Error == "" {
+		t.Errorf("panicked operation = %+v", op)
+	}
+	if op := waitForOperation(t, oc, after.ID); op.Status != OperationSucceeded || op.Result != "after" {
+		t.Errorf("operation after the panic = %+v", op)
+	}
+}
+
+func TestOperationControllerNotFound(t *testing.T) {
+	oc := NewOperationController(1, 1, time.Minute)
+	defer oc.Stop()
+	if _, err := oc.Get("missing"); err != errOperationNotFound {
+		t.Errorf("err = %v, want %v", err, errOperationNotFound)
+	}
+}
+
+func TestOperationControllerOnFinish(t *testing.T) {
+	oc := NewOperationController(1, 10, time.Minute)
+	defer oc.Stop()
+	release := make(chan struct{})
+	op, err := oc.Submit("install", "", "default", "web", func() (interface{}, error) {
+		<-release
+		return nil, errors.New("boom")
+	})
+	if err != nil {
+		t.Fatalf("unable to submit: %v", err)
+	}
+
+	finished := make(chan Operation, 2)
+	if err := oc.OnFinish(op.ID, func(op Operation) { finished <- op }); err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	select {
+	case <-finished:
+		t.Fatal("listener called before the operation finished")
+	default:
+	}
+	close(release)
+
+	select {
+	case got := <-finished:
+		if got.Status != OperationFailed || got.Error != "boom" {
+			t.Errorf("finished operation = %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener not called")
+	}
+
+	// listening to a finished operation calls the listener right away
+	if err := oc.OnFinish(op.ID, func(op Operation) { finished <- op }); err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	if got := <-finished; got.Status != OperationFailed {
+		t.Errorf("finished operation = %+v", got)
+	}
+	if err := oc.OnFinish("missing", func(Operation) {}); err != errOperationNotFound {
+		t.Errorf("err = %v, want %v", err, errOperationNotFound)
+	}
+}
+
+func TestOperationControllerStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+	oc := NewOperationController(4, 10, time.Minute)
+	oc.Stop()
+	// stopping twice is fine
+	oc.Stop()
+
+	// the workers and the cleanup exit
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,15 +1,22 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/semver"
 	log "github.com/Sirupsen/logrus"
-	"github.com/ghodss/yaml"
+	"golang.org/x/net/context"
 	"k8s.io/helm/pkg/chartutil"
-	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/proto/hapi/release"
 	tiller "k8s.io/helm/pkg/proto/hapi/services"
-
-	"fmt"
+	hapi_version "k8s.io/helm/pkg/proto/hapi/version"
+	"k8s.io/helm/pkg/timeconv"
+	helm_version "k8s.io/helm/pkg/version"
 
 	"github.com/AcalephStorage/rudder/internal/client"
+	"github.com/AcalephStorage/rudder/internal/util"
 )
 
 // GetReleaseResponse contains the response for requesting Release information
@@ -18,32 +25,184 @@ type GetReleaseResponse struct {
 	Status  *tiller.GetReleaseStatusResponse  `json:"status"`
 }
 
+// ListReleasesResponse is a page of releases. Next is the offset to use for requesting the
+// following page and is empty on the last page. Total is the number of releases matching the request.
+type ListReleasesResponse struct {
+	Releases []*release.Release `json:"releases"`
+	Count    int64              `json:"count"`
+	Next     string             `json:"next"`
+	Total    int64              `json:"total"`
+}
+
+// ReleaseRevision contains the details of a single revision of a release
+type ReleaseRevision struct {
+	Revision     int32     `json:"revision"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chart_version"`
+	Status       string    `json:"status"`
+	Description  string    `json:"description"`
+	Updated      time.Time `json:"updated"`
+}
+
+// ReleaseTestMessage is a single message streamed back while running release tests. The
+// final message has Done set along with the overall result.
+type ReleaseTestMessage struct {
+	Message string `json:"message,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Done    bool   `json:"done,omitempty"`
+	Passed  bool   `json:"passed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// VersionResponse contains the versions of rudder, the helm library it was built with and tiller
+type VersionResponse struct {
+	Rudder string                `json:"rudder"`
+	Helm   string                `json:"helm"`
+	Tiller *hapi_version.Version `json:"tiller"`
+}
+
+// InstallOptions are the tiller options for installing a release
+type InstallOptions struct {
+	DryRun       bool  `json:"dry_run"`
+	ReuseName    bool  `json:"reuse_name"`
+	Wait         bool  `json:"wait"`
+	Timeout      int64 `json:"timeout"`
+	DisableHooks bool  `json:"disable_hooks"`
+}
+
+// UpdateOptions are the tiller options for updating a release
+type UpdateOptions struct {
+	DryRun       bool  `json:"dry_run"`
+	ReuseValues  bool  `json:"reuse_values"`
+	ResetValues  bool  `json:"reset_values"`
+	Force        bool  `json:"force"`
+	Recreate     bool  `json:"recreate"`
+	Wait         bool  `json:"wait"`
+	Timeout      int64 `json:"timeout"`
+	DisableHooks bool  `json:"disable_hooks"`
+}
+
+// Validate checks for conflicting update options
+func (uo *UpdateOptions) Validate() error {
+	if uo.ReuseValues && uo.ResetValues {
+		return errReuseAndResetValues
+	}
+	if uo.Timeout < 0 {
+		return errNegativeTimeout
+	}
+	if uo.Wait && uo.Timeout == 0 {
+		return errWaitWithoutTimeout
+	}
+	return nil
+}
+
+// DryRunResponse contains what tiller rendered for a dry-run install or update
+type DryRunResponse struct {
+	Name      string          `json:"name"`
+	Namespace string          `json:"namespace"`
+	Revision  int32           `json:"revision"`
+	Manifest  string          `json:"manifest"`
+	Hooks     []*release.Hook `json:"hooks"`
+	Notes     string          `json:"notes"`
+}
+
+// NewDryRunResponse extracts the rendered manifest, hooks and notes from a release
+func NewDryRunResponse(rel *release.Release) *DryRunResponse {
+	return &DryRunResponse{
+		Name:      rel.GetName(),
+		Namespace: rel.GetNamespace(),
+		Revision:  rel.GetVersion(),
+		Manifest:  rel.GetManifest(),
+		Hooks:     rel.GetHooks(),
+		Notes:     rel.GetInfo().GetStatus().GetNotes(),
+	}
+}
+
+// ReleaseDiffResponse contains the per object differences between two versions of a release
+type ReleaseDiffResponse struct {
+	Release string         `json:"release"`
+	From    int32          `json:"from"`
+	To      int32          `json:"to"`
+	Changes []ManifestDiff `json:"changes"`
+}
+
+var (
+	errReuseAndResetValues = errors.New("reuse_values and reset_values can't be used together")
+	errNegativeTimeout     = errors.New("timeout can't be negative")
+	errWaitWithoutTimeout  = errors.New("wait requires a timeout")
+	errIncompatibleTiller  = errors.New("tiller major version does not match the helm client version")
+	errNoPreviousRevision  = errors.New("release has no previous revision to compare with")
+)
+
+// ReleaseStatus contains the status of a release revision. Resources is the status of the
+// kubernetes resources as reported by tiller.
+type ReleaseStatus struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Status       string    `json:"status"`
+	Description  string    `json:"description"`
+	LastDeployed time.Time `json:"last_deployed"`
+	Resources    string    `json:"resources"`
+}
+
+// ReleaseNotes contains the rendered NOTES.txt of a release revision
+type ReleaseNotes struct {
+	Name  string `json:"name"`
+	Notes string `json:"notes"`
+}
+
+// ReleaseHook contains the details of a release hook
+type ReleaseHook struct {
+	Name           string    `json:"name"`
+	Kind           string    `json:"kind"`
+	Path           string    `json:"path"`
+	Events         []string  `json:"events"`
+	Weight         int32     `json:"weight"`
+	DeletePolicies []string  `json:"delete_policies"`
+	LastRun        time.Time `json:"last_run"`
+}
+
 // ReleaseController handles helm release related operations
 type ReleaseController struct {
-	tillerClient   *client.TillerClient
-	repoController *RepoController
+	cluster           string
+	tillerClient      client.ReleaseBackend
+	repoController    *RepoController
+	webhookController *WebhookController
 }
 
-// NewReleaseController creates a new Release controller
-func NewReleaseController(tillerClient *client.TillerClient, repoController *RepoController) *ReleaseController {
+// NewReleaseController creates a new Release controller for the releases of the cluster. Release
+// lifecycle events are sent to the webhooks of webhookController.
+func NewReleaseController(cluster string, tillerClient client.ReleaseBackend, repoController *RepoController, webhookController *WebhookController) *ReleaseController {
 	return &ReleaseController{
-		tillerClient:   tillerClient,
-		repoController: repoController,
+		cluster:           cluster,
+		tillerClient:      tillerClient,
+		repoController:    repoController,
+		webhookController: webhookController,
 	}
 }
 
-// ListReleases returns a list of releases
-func (rc *ReleaseController) ListReleases(req *tiller.ListReleasesRequest) (*tiller.ListReleasesResponse, error) {
-	res, err := rc.tillerClient.ListReleases(req)
+// ListReleases returns a page of releases
+func (rc *ReleaseController) ListReleases(ctx context.Context, req *tiller.ListReleasesRequest) (*ListReleasesResponse, error) {
+	res, err := rc.tillerClient.ListReleases(ctx, req)
 	if err != nil {
 		log.WithError(err).Error("unable to get list of releases from tiller")
 		return nil, err
 	}
-	return res, nil
+	releases := res.Releases
+	if releases == nil {
+		releases = make([]*release.Release, 0)
+	}
+	return &ListReleasesResponse{
+		Releases: releases,
+		Count:    int64(len(releases)),
+		Next:     res.Next,
+		Total:    res.Total,
+	}, nil
 }
 
-// InstallRelease installs a new release of the provided chart
-func (rc *ReleaseController) InstallRelease(name, namespace, repo, chart, version string, values map[string]interface{}) (*tiller.InstallReleaseResponse, error) {
+// InstallRelease installs a new release of the provided chart. values should already be merged
+// using MergeValues. If opts.DryRun is set, tiller only renders the chart and nothing is persisted.
+func (rc *ReleaseController) InstallRelease(ctx context.Context, name, namespace, repo, chart, version string, values map[string]interface{}, opts InstallOptions) (*tiller.InstallReleaseResponse, error) {
 	chartDetails, err := rc.repoController.ChartDetails(repo, chart, version)
 	if err != nil {
 		log.WithError(err).Error("unable to get chart details")
@@ -56,26 +215,28 @@ func (rc *ReleaseController) InstallRelease(name, namespace, repo, chart, versio
 		log.WithError(err).Error("unable to load chart details")
 		return nil, err
 	}
-	raw, _ := yaml.Marshal(values)
-
-	inValues := make(map[string]*hapi_chart.Value)
-	for k, v := range values {
-		inValues[k] = &hapi_chart.Value{Value: fmt.Sprintf("%v", v)}
-	}
-
-	config := &hapi_chart.Config{
-		Raw:    string(raw),
-		Values: inValues,
+	config, err := valuesConfig(values)
+	if err != nil {
+		log.WithError(err).Error("unable to convert values")
+		return nil, err
 	}
 
 	req := &tiller.InstallReleaseRequest{
-		Name:      name,
-		Namespace: namespace,
-		Chart:     inChart,
-		Values:    config,
+		Name:         name,
+		Namespace:    namespace,
+		Chart:        inChart,
+		Values:       config,
+		DryRun:       opts.DryRun,
+		ReuseName:    opts.ReuseName,
+		Wait:         opts.Wait,
+		Timeout:      opts.Timeout,
+		DisableHooks: opts.DisableHooks,
 	}
 
-	res, err := rc.tillerClient.InstallRelease(req)
+	res, err := rc.tillerClient.InstallRelease(ctx, req)
+	if !opts.DryRun {
+		rc.webhookController.Notify(rc.cluster, WebhookInstall, name, res.GetRelease(), err)
+	}
 	if err != nil {
 		log.WithError(err).Error("unable to install new release")
 		return nil, err
@@ -83,8 +244,10 @@ func (rc *ReleaseController) InstallRelease(name, namespace, repo, chart, versio
 	return res, nil
 }
 
-// UpdateRelease updates an existing release of the provided chart
-func (rc *ReleaseController) UpdateRelease(name, repo, chart, version string, values map[string]interface{}) (*tiller.UpdateReleaseResponse, error) {
+// UpdateRelease updates an existing release of the provided chart. values should already be merged
+// using MergeValues and opts should be validated. If opts.DryRun is set, tiller only renders the
+// chart and nothing is persisted.
+func (rc *ReleaseController) UpdateRelease(ctx context.Context, name, repo, chart, version string, values map[string]interface{}, opts UpdateOptions) (*tiller.UpdateReleaseResponse, error) {
 	chartDetails, err := rc.repoController.ChartDetails(repo, chart, version)
 	if err != nil {
 		log.WithError(err).Error("unable to get chart details")
@@ -97,25 +260,30 @@ func (rc *ReleaseController) UpdateRelease(name, repo, chart, version string, va
 		log.WithError(err).Error("unable to load chart details")
 		return nil, err
 	}
-	raw, _ := yaml.Marshal(values)
-
-	inValues := make(map[string]*hapi_chart.Value)
-	for k, v := range values {
-		inValues[k] = &hapi_chart.Value{Value: fmt.Sprintf("%v", v)}
-	}
-
-	config := &hapi_chart.Config{
-		Raw:    string(raw),
-		Values: inValues,
+	config, err := valuesConfig(values)
+	if err != nil {
+		log.WithError(err).Error("unable to convert values")
+		return nil, err
 	}
 
 	req := &tiller.UpdateReleaseRequest{
-		Name:   name,
-		Chart:  inChart,
-		Values: config,
+		Name:         name,
+		Chart:        inChart,
+		Values:       config,
+		DryRun:       opts.DryRun,
+		ReuseValues:  opts.ReuseValues,
+		ResetValues:  opts.ResetValues,
+		Force:        opts.Force,
+		Recreate:     opts.Recreate,
+		Wait:         opts.Wait,
+		Timeout:      opts.Timeout,
+		DisableHooks: opts.DisableHooks,
 	}
 
-	res, err := rc.tillerClient.UpdateRelease(req)
+	res, err := rc.tillerClient.UpdateRelease(ctx, req)
+	if !opts.DryRun {
+		rc.webhookController.Notify(rc.cluster, WebhookUpgrade, name, res.GetRelease(), err)
+	}
 	if err != nil {
 		log.WithError(err).Error("unable to update release")
 		return nil, err
@@ -123,14 +291,105 @@ func (rc *ReleaseController) UpdateRelease(name, repo, chart, version string, va
 	return res, nil
 }
 
+// UpsertRelease installs the release if it doesn't exist yet and updates it otherwise. Releases
+// that were deleted, or that failed without ever being deployed, are installed again reusing the
+// release name and namespace. The response of an install is returned as an UpdateReleaseResponse.
+func (rc *ReleaseController) UpsertRelease(ctx context.Context, name, namespace, repo, chart, version string, values map[string]interface{}, opts UpdateOptions) (*tiller.UpdateReleaseResponse, error) {
+	install, namespace, err := rc.mustInstall(ctx, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !install {
+		return rc.UpdateRelease(ctx, name, repo, chart, version, values, opts)
+	}
+
+	log.Infof("release %s is not deployed, installing instead", name)
+	installOpts := InstallOptions{
+		DryRun:       opts.DryRun,
+		ReuseName:    true,
+		Wait:         opts.Wait,
+		Timeout:      opts.Timeout,
+		DisableHooks: opts.DisableHooks,
+	}
+	res, err := rc.InstallRelease(ctx, name, namespace, repo, chart, version, values, installOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &tiller.UpdateReleaseResponse{Release: res.Release}, nil
+}
+
+// mustInstall checks if a release has to be installed rather than updated, and returns the
+// namespace of the release. An existing release keeps its namespace, even when it is installed again.
+func (rc *ReleaseController) mustInstall(ctx context.Context, name, namespace string) (bool, string, error) {
+	req := &tiller.GetReleaseStatusRequest{Name: name}
+	res, err := rc.tillerClient.GetReleaseStatus(ctx, req)
+	if err != nil {
+		if isReleaseNotFound(err) {
+			if namespace == "" {
+				namespace = "default"
+			}
+			return true, namespace, nil
+		}
+		log.WithError(err).Errorf("unable to get status of %s", name)
+		return false, "", err
+	}
+	if namespace != "" && res.Namespace != namespace {
+		log.Warnf("release %s exists in namespace %s, ignoring namespace %s", name, res.Namespace, namespace)
+	}
+
+	switch res.GetInfo().GetStatus().GetCode() {
+	case release.Status_DELETED:
+		return true, res.Namespace, nil
+	case release.Status_FAILED:
+		deployed, err := rc.wasDeployed(ctx, name)
+		return !deployed, res.Namespace, err
+	}
+	return false, res.Namespace, nil
+}
+
+// wasDeployed checks if any revision of the release was successfully deployed
+func (rc *ReleaseController) wasDeployed(ctx context.Context, name string) (bool, error) {
+	revisions, err := rc.ReleaseHistory(ctx, name, 256)
+	if err != nil {
+		return false, err
+	}
+	for _, revision := range revisions {
+		switch revision.Status {
+		case release.Status_DEPLOYED.String(), release.Status_SUPERSEDED.String():
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isReleaseNotFound checks if tiller failed because the release doesn't exist
+func isReleaseNotFound(err error) bool {
+	return util.IsError(err, client.ErrReleaseNotFound)
+}
+
+// ReleaseNamespace returns the namespace of the release, or an empty string if the release doesn't exist
+func (rc *ReleaseController) ReleaseNamespace(ctx context.Context, name string) (string, error) {
+	req := &tiller.GetReleaseStatusRequest{Name: name}
+	res, err := rc.tillerClient.GetReleaseStatus(ctx, req)
+	if err != nil {
+		if isReleaseNotFound(err) {
+			return "", nil
+		}
+		log.WithError(err).Errorf("unable to get namespace of %s", name)
+		return "", err
+	}
+	return res.Namespace, nil
+}
+
 // UninstallRelease uninstall a release
-func (rc *ReleaseController) UninstallRelease(releaseName string, purge bool) (*tiller.UninstallReleaseResponse, error) {
+func (rc *ReleaseController) UninstallRelease(ctx context.Context, releaseName string, purge bool) (*tiller.UninstallReleaseResponse, error) {
 	req := &tiller.UninstallReleaseRequest{
 		Name:  releaseName,
 		Purge: purge,
 	}
 
-	res, err := rc.tillerClient.UninstallRelease(req)
+	res, err := rc.tillerClient.UninstallRelease(ctx, req)
+	rc.webhookController.Notify(rc.cluster, WebhookUninstall, releaseName, res.GetRelease(), err)
 	if err != nil {
 		log.WithError(err).Error("unable to uninstall release")
 		return nil, err
@@ -138,27 +397,312 @@ func (rc *ReleaseController) UninstallRelease(releaseName string, purge bool) (*
 	return res, nil
 }
 
-// GetRelease returns the release details
-func (rc *ReleaseController) GetRelease(name string, version int32) (*GetReleaseResponse, error) {
+// RollbackRelease rolls back a release to the version given in the request
+func (rc *ReleaseController) RollbackRelease(ctx context.Context, req *tiller.RollbackReleaseRequest) (*tiller.RollbackReleaseResponse, error) {
+	res, err := rc.tillerClient.RollbackRelease(ctx, req)
+	if !req.DryRun {
+		rc.webhookController.Notify(rc.cluster, WebhookRollback, req.Name, res.GetRelease(), err)
+	}
+	if err != nil {
+		log.WithError(err).Error("unable to rollback release")
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReleaseHistory returns the revisions of a release, newest first
+func (rc *ReleaseController) ReleaseHistory(ctx context.Context, name string, max int32) ([]ReleaseRevision, error) {
+	req := &tiller.GetHistoryRequest{
+		Name: name,
+		Max:  max,
+	}
+	res, err := rc.tillerClient.GetHistory(ctx, req)
+	if err != nil {
+		log.WithError(err).Error("unable to get release history")
+		return nil, err
+	}
+
+	revisions := make([]ReleaseRevision, 0, len(res.Releases))
+	for _, r := range res.Releases {
+		revision := ReleaseRevision{Revision: r.Version}
+		if md := r.GetChart().GetMetadata(); md != nil {
+			revision.Chart = md.Name
+			revision.ChartVersion = md.Version
+		}
+		if info := r.GetInfo(); info != nil {
+			revision.Status = info.GetStatus().GetCode().String()
+			revision.Description = info.Description
+			if info.LastDeployed != nil {
+				revision.Updated = timeconv.Time(info.LastDeployed)
+			}
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+// TestRelease runs the tests of a release. handle is called for every test message received from
+// tiller. passed is false if any of the tests failed.
+func (rc *ReleaseController) TestRelease(ctx context.Context, name string, timeout int64, cleanup bool, handle func(*ReleaseTestMessage) error) (passed bool, err error) {
+	req := &tiller.TestReleaseRequest{
+		Name:    name,
+		Timeout: timeout,
+		Cleanup: cleanup,
+	}
+	failed := 0
+	err = rc.tillerClient.RunReleaseTest(ctx, req, func(res *tiller.TestReleaseResponse) error {
+		if res.Status == release.TestRun_FAILURE {
+			failed++
+		}
+		return handle(&ReleaseTestMessage{
+			Message: res.Msg,
+			Status:  res.Status.String(),
+			Passed:  res.Status != release.TestRun_FAILURE,
+		})
+	})
+	if err != nil {
+		log.WithError(err).Error("unable to run release tests")
+		rc.webhookController.Notify(rc.cluster, WebhookTest, name, nil, err)
+		return false, err
+	}
+	if failed > 0 {
+		rc.webhookController.Notify(rc.cluster, WebhookTest, name, nil, fmt.Errorf("%d test(s) failed", failed))
+		return false, nil
+	}
+	rc.webhookController.Notify(rc.cluster, WebhookTest, name, nil, nil)
+	return true, nil
+}
+
+// GetVersion returns the versions of rudder, helm and tiller
+func (rc *ReleaseController) GetVersion(ctx context.Context, rudderVersion string) (*VersionResponse, error) {
+	res, err := rc.tillerClient.GetVersion(ctx)
+	if err != nil {
+		log.WithError(err).Error("unable to get tiller version")
+		return nil, err
+	}
+	return &VersionResponse{
+		Rudder: rudderVersion,
+		Helm:   helm_version.GetVersion(),
+		Tiller: res.Version,
+	}, nil
+}
+
+// CheckTillerVersion verifies that tiller is compatible with the vendored helm client. A
+// warning is logged for minor version differences or if tiller can't be reached, an error is
+// returned if the major versions differ.
+func (rc *ReleaseController) CheckTillerVersion(ctx context.Context) error {
+	res, err := rc.tillerClient.GetVersion(ctx)
+	if err != nil {
+		log.WithError(err).Warn("unable to reach tiller, skipping version compatibility check")
+		return nil
+	}
+	clientVersion := helm_version.Version
+	serverVersion := res.GetVersion().GetSemVer()
+	cv, err := semver.NewVersion(clientVersion)
+	if err != nil {
+		log.WithError(err).Warnf("unable to parse helm client version %s", clientVersion)
+		return nil
+	}
+	sv, err := semver.NewVersion(serverVersion)
+	if err != nil {
+		log.WithError(err).Warnf("unable to parse tiller version %s", serverVersion)
+		return nil
+	}
+	if cv.Major() != sv.Major() {
+		log.Errorf("tiller %s is incompatible with helm client %s", serverVersion, clientVersion)
+		return errIncompatibleTiller
+	}
+	if cv.Minor() != sv.Minor() {
+		log.Warnf("tiller %s and helm client %s differ in minor version, some operations may fail", serverVersion, clientVersion)
+		return nil
+	}
+	log.Infof("tiller %s is compatible with helm client %s", serverVersion, clientVersion)
+	return nil
+}
+
+// DiffRevisions compares the manifests of two revisions of a release. If to is 0, the deployed
+// revision is used. If from is 0, the revision before to is used.
+func (rc *ReleaseController) DiffRevisions(ctx context.Context, name string, from, to int32) (*ReleaseDiffResponse, error) {
+	toRelease, err := rc.releaseContent(ctx, name, to)
+	if err != nil {
+		return nil, err
+	}
+	if from == 0 {
+		from = toRelease.Version - 1
+	}
+	if from < 1 {
+		log.WithError(errNoPreviousRevision).Errorf("unable to diff revision %d of %s", toRelease.Version, name)
+		return nil, errNoPreviousRevision
+	}
+	fromRelease, err := rc.releaseContent(ctx, name, from)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diffManifests(fromRelease.Manifest, toRelease.Manifest, toRelease.Namespace)
+	if err != nil {
+		log.WithError(err).Error("unable to diff release manifests")
+		return nil, err
+	}
+	return &ReleaseDiffResponse{
+		Release: name,
+		From:    fromRelease.Version,
+		To:      toRelease.Version,
+		Changes: changes,
+	}, nil
+}
+
+// DiffUpdate compares the deployed revision of a release with a dry-run update of the release
+func (rc *ReleaseController) DiffUpdate(ctx context.Context, name, repo, chart, version string, values map[string]interface{}, opts UpdateOptions) (*ReleaseDiffResponse, error) {
+	current, err := rc.releaseContent(ctx, name, 0)
+	if err != nil {
+		return nil, err
+	}
+	opts.DryRun = true
+	proposed, err := rc.UpdateRelease(ctx, name, repo, chart, version, values, opts)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diffManifests(current.Manifest, proposed.Release.GetManifest(), current.Namespace)
+	if err != nil {
+		log.WithError(err).Error("unable to diff release manifests")
+		return nil, err
+	}
+	return &ReleaseDiffResponse{
+		Release: name,
+		From:    current.Version,
+		To:      proposed.Release.GetVersion(),
+		Changes: changes,
+	}, nil
+}
+
+// ReleaseObjects returns the kubernetes objects in the manifest of a release revision
+func (rc *ReleaseController) ReleaseObjects(ctx context.Context, name string, version int32) ([]KubeObject, error) {
+	rel, err := rc.releaseContent(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	manifestObjects, err := parseManifest(rel.Manifest, rel.Namespace)
+	if err != nil {
+		log.WithError(err).Error("unable to parse release manifest")
+		return nil, err
+	}
+	objects := make([]KubeObject, len(manifestObjects))
+	for i, obj := range manifestObjects {
+		objects[i] = obj.KubeObject
+	}
+	return objects, nil
+}
+
+// releaseContent returns a single revision of a release
+func (rc *ReleaseController) releaseContent(ctx context.Context, name string, version int32) (*release.Release, error) {
+	res, err := rc.ReleaseContent(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	return res.Release, nil
+}
+
+// ReleaseStatus returns the status of a release revision
+func (rc *ReleaseController) ReleaseStatus(ctx context.Context, name string, version int32) (*ReleaseStatus, error) {
+	res, err := rc.releaseStatus(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	status := &ReleaseStatus{
+		Name:      res.Name,
+		Namespace: res.Namespace,
+	}
+	if info := res.GetInfo(); info != nil {
+		status.Status = info.GetStatus().GetCode().String()
+		status.Resources = info.GetStatus().GetResources()
+		status.Description = info.Description
+		if info.LastDeployed != nil {
+			status.LastDeployed = timeconv.Time(info.LastDeployed)
+		}
+	}
+	return status, nil
+}
+
+// ReleaseContent returns the content of a release revision
+func (rc *ReleaseController) ReleaseContent(ctx context.Context, name string, version int32) (*tiller.GetReleaseContentResponse, error) {
 	req := &tiller.GetReleaseContentRequest{
 		Name:    name,
 		Version: version,
 	}
-	content, err := rc.tillerClient.GetReleaseContent(req)
+	res, err := rc.tillerClient.GetReleaseContent(ctx, req)
 	if err != nil {
 		log.WithError(err).Error("unable to get release content")
 		return nil, err
 	}
+	return res, nil
+}
+
+// ReleaseNotes returns the rendered notes of a release revision
+func (rc *ReleaseController) ReleaseNotes(ctx context.Context, name string, version int32) (*ReleaseNotes, error) {
+	res, err := rc.releaseStatus(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	return &ReleaseNotes{
+		Name:  res.Name,
+		Notes: res.GetInfo().GetStatus().GetNotes(),
+	}, nil
+}
 
-	req2 := &tiller.GetReleaseStatusRequest{
+// ReleaseHooks returns the hooks of a release revision
+func (rc *ReleaseController) ReleaseHooks(ctx context.Context, name string, version int32) ([]ReleaseHook, error) {
+	rel, err := rc.releaseContent(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	hooks := make([]ReleaseHook, len(rel.Hooks))
+	for i, h := range rel.Hooks {
+		hook := ReleaseHook{
+			Name:           h.Name,
+			Kind:           h.Kind,
+			Path:           h.Path,
+			Weight:         h.Weight,
+			Events:         make([]string, len(h.Events)),
+			DeletePolicies: make([]string, len(h.DeletePolicies)),
+		}
+		for j, event := range h.Events {
+			hook.Events[j] = event.String()
+		}
+		for j, policy := range h.DeletePolicies {
+			hook.DeletePolicies[j] = policy.String()
+		}
+		if h.LastRun != nil {
+			hook.LastRun = timeconv.Time(h.LastRun)
+		}
+		hooks[i] = hook
+	}
+	return hooks, nil
+}
+
+// releaseStatus returns the tiller status of a release revision
+func (rc *ReleaseController) releaseStatus(ctx context.Context, name string, version int32) (*tiller.GetReleaseStatusResponse, error) {
+	req := &tiller.GetReleaseStatusRequest{
 		Name:    name,
 		Version: version,
 	}
-	status, err := rc.tillerClient.GetReleaseStatus(req2)
+	res, err := rc.tillerClient.GetReleaseStatus(ctx, req)
 	if err != nil {
 		log.WithError(err).Error("unable to get release status")
 		return nil, err
 	}
+	return res, nil
+}
+
+// GetRelease returns the release details
+func (rc *ReleaseController) GetRelease(ctx context.Context, name string, version int32) (*GetReleaseResponse, error) {
+	content, err := rc.ReleaseContent(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	status, err := rc.releaseStatus(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
 	return &GetReleaseResponse{
 		Content: content,
 		Status:  status,
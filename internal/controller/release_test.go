@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/net/context"
+	"k8s.io/helm/pkg/proto/hapi/release"
+	tiller "k8s.io/helm/pkg/proto/hapi/services"
+
+	"github.com/AcalephStorage/rudder/internal/client/fake"
+)
+
+// newTestReleaseController starts a fake tiller and returns a release controller connected to
+// it. stop closes the connection and stops the fake tiller.
+func newTestReleaseController(t *testing.T) (ts *fake.TillerServer, rc *ReleaseController, stop func()) {
+	ts = fake.NewTillerServer()
+	ts.Start()
+	tillerClient, err := ts.NewClient()
+	if err != nil {
+		ts.Stop()
+		t.Fatalf("unable to connect to the fake tiller: %v", err)
+	}
+	stop = func() {
+		tillerClient.Close()
+		ts.Stop()
+	}
+	return ts, NewReleaseController("test", tillerClient, nil, nil), stop
+}
+
+// withStatus returns a release revision with the status code
+func withStatus(name, namespace string, revision int32, code release.Status_Code) *release.Release {
+	rel := fake.NewRelease(name, namespace, revision)
+	rel.Info.Status.Code = code
+	return rel
+}
+
+func TestReleaseNamespace(t *testing.T) {
+	ts, rc, stop := newTestReleaseController(t)
+	defer stop()
+	ts.AddRelease(fake.NewRelease("web", "team-a", 1))
+
+	if namespace, err := rc.ReleaseNamespace(context.Background(), "web"); err != nil || namespace != "team-a" {
+		t.Errorf("namespace of web = %q, %v, want team-a", namespace, err)
+	}
+	if namespace, err := rc.ReleaseNamespace(context.Background(), "missing"); err != nil || namespace != "" {
+		t.Errorf("namespace of a missing release = %q, %v, want none", namespace, err)
+	}
+}
+
+func TestMustInstall(t *testing.T) {
+	ts, rc, stop := newTestReleaseController(t)
+	defer stop()
+	ts.AddRelease(fake.NewRelease("deployed", "team-a", 1))
+	ts.AddRelease(withStatus("deleted", "team-a", 1, release.Status_DELETED))
+	ts.AddRelease(withStatus("never-deployed", "team-a", 1, release.Status_FAILED))
+	ts.AddRelease(withStatus("failed-upgrade", "team-a", 1, release.Status_SUPERSEDED))
+	ts.AddRelease(withStatus("failed-upgrade", "team-a", 2, release.Status_FAILED))
+
+	tests := []struct {
+		name      string
+		release   string
+		namespace string
+		install   bool
+		want      string
+	}{
+		{name: "missing release", release: "missing", install: true, want: "default"},
+		{name: "missing release in a namespace", release: "missing", namespace: "team-b", install: true, want: "team-b"},
+		{name: "deployed release", release: "deployed", namespace: "team-b", want: "team-a"},
+		{name: "deleted release keeps its namespace", release: "deleted", namespace: "team-b", install: true, want: "team-a"},
+		{name: "failed without being deployed", release: "never-deployed", install: true, want: "team-a"},
+		{name: "failed after being deployed", release: "failed-upgrade", want: "team-a"},
+	}
+	for _, test := range tests {
+		install, namespace, err := rc.mustInstall(context.Background(), test.release, test.namespace)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if install != test.install || namespace != test.want {
+			t.Errorf("%s: mustInstall() = %v, %s, want %v, %s", test.name, install, namespace, test.install, test.want)
+		}
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	ts, rc, stop := newTestReleaseController(t)
+	defer stop()
+	first := withStatus("web", "default", 1, release.Status_SUPERSEDED)
+	first.Manifest = testService
+	ts.AddRelease(first)
+	second := fake.NewRelease("web", "default", 2)
+	second.Manifest = testServiceChanged + "---\n" + testConfigMap
+	ts.AddRelease(second)
+	failed := withStatus("web", "default", 3, release.Status_FAILED)
+	ts.AddRelease(failed)
+
+	// revision 0 is the deployed revision, not the failed latest one
+	diff, err := rc.DiffRevisions(context.Background(), "web", 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff.From != 1 || diff.To != 2 {
+		t.Errorf("diff = %d..%d, want 1..2", diff.From, diff.To)
+	}
+	changes := make(map[string]string)
+	for _, change := range diff.Changes {
+		changes[change.Kind] = change.Change
+	}
+	if len(diff.Changes) != 2 || changes["ConfigMap"] != ChangeAdded || changes["Service"] != ChangeChanged {
+		t.Errorf("changes = %+v, want the added config map and the changed service", diff.Changes)
+	}
+
+	if _, err := rc.DiffRevisions(context.Background(), "web", 0, 1); err != errNoPreviousRevision {
+		t.Errorf("err = %v, want %v", err, errNoPreviousRevision)
+	}
+	if _, err := rc.DiffRevisions(context.Background(), "missing", 0, 0); !isReleaseNotFound(err) {
+		t.Errorf("err = %v, want release not found", err)
+	}
+}
+
+func TestRollbackRelease(t *testing.T) {
+	ts, rc, stop := newTestReleaseController(t)
+	defer stop()
+	first := withStatus("web", "default", 1, release.Status_SUPERSEDED)
+	first.Manifest = testService
+	ts.AddRelease(first)
+	second := fake.NewRelease("web", "default", 2)
+	second.Manifest = testServiceChanged
+	ts.AddRelease(second)
+
+	res, err := rc.RollbackRelease(context.Background(), &tiller.RollbackReleaseRequest{Name: "web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Release.Version != 3 || res.Release.Manifest != testService {
+		t.Errorf("rolled back release = %v, want revision 3 with the manifest of revision 1", res.Release)
+	}
+
+	history, err := rc.ReleaseHistory(context.Background(), "web", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{release.Status_DEPLOYED.String(), release.Status_SUPERSEDED.String(), release.Status_SUPERSEDED.String()}
+	if len(history) != len(want) {
+		t.Fatalf("got %d revisions, want %d", len(history), len(want))
+	}
+	for i, revision := range history {
+		if revision.Revision != int32(len(want)-i) || revision.Status != want[i] {
+			t.Errorf("revision %d = %+v, want status %s", i, revision, want[i])
+		}
+	}
+}
+
+func TestTestRelease(t *testing.T) {
+	ts, rc, stop := newTestReleaseController(t)
+	defer stop()
+	ts.AddRelease(fake.NewRelease("web", "default", 1))
+	ts.SetTestResults([]*tiller.TestReleaseResponse{
+		{Msg: "RUNNING: web-test", Status: release.TestRun_RUNNING},
+		{Msg: "FAILED: web-test", Status: release.TestRun_FAILURE},
+	})
+
+	var messages []*ReleaseTestMessage
+	passed, err := rc.TestRelease(context.Background(), "web", 300, false, func(msg *ReleaseTestMessage) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if passed {
+		t.Error("passed with a failed test")
+	}
+	if len(messages) != 2 || !messages[0].Passed || messages[1].Passed {
+		t.Errorf("messages = %+v", messages)
+	}
+
+	if _, err := rc.TestRelease(context.Background(), "missing", 300, false, func(*ReleaseTestMessage) error { return nil }); !isReleaseNotFound(err) {
+		t.Errorf("err = %v, want release not found", err)
+	}
+}
+
+func TestCheckTillerVersion(t *testing.T) {
+	ts, rc, stop := newTestReleaseController(t)
+	defer stop()
+	if err := rc.CheckTillerVersion(context.Background()); err != nil {
+		t.Errorf("same version: unexpected error: %v", err)
+	}
+	ts.SetVersion("v3.0.0")
+	if err := rc.CheckTillerVersion(context.Background()); err != errIncompatibleTiller {
+		t.Errorf("major version difference: err = %v, want %v", err, errIncompatibleTiller)
+	}
+	ts.SetError(fake.GetVersion, errors.New("tiller is down"))
+	if err := rc.CheckTillerVersion(context.Background()); err != nil {
+		t.Errorf("unreachable tiller: unexpected error: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"regexp"
 	"strings"
@@ -16,6 +17,12 @@ import (
 	"github.com/AcalephStorage/rudder/internal/util"
 )
 
+// repo errors. errors returned by RepoController wrap these, so they can be checked using util.IsError.
+var (
+	ErrRepoNotFound  = errors.New("repository not found")
+	ErrChartNotFound = errors.New("chart not found")
+)
+
 // RepoController handles helm repository related operations
 type RepoController struct {
 	repos         []*repo.Entry
@@ -59,7 +66,7 @@ func (rc *RepoController) findRepo(repoName string) (r *repo.Entry, err error) {
 			return
 		}
 	}
-	err = errors.New("repository not found")
+	err = util.WrapError(ErrRepoNotFound, fmt.Errorf("no repository named %s", repoName))
 	return
 }
 
@@ -74,13 +81,14 @@ func (rc *RepoController) ListCharts(repoName, filter string) (charts map[string
 	indexURL := repoURL + "/index.yaml"
 	data, err := rc.readFromCacheOrURL(indexURL)
 	if err != nil {
-		log.WithError(err).Error("Unable to get index.yaml from cache or %s", indexURL)
+		log.WithError(err).Errorf("Unable to get index.yaml from cache or %s", indexURL)
 		return
 	}
 	var index repo.IndexFile
 	err = util.YAMLtoJSON(data, &index)
 	if err != nil {
 		log.WithError(err).Error("Unable to parse index.yaml")
+		return
 	}
 	charts = index.Entries
 	filterCharts(charts, filter)
@@ -99,6 +107,12 @@ func (rc *RepoController) ChartDetails(repoName, chartName, chartVersion string)
 	version, found := findVersion(versions, chartVersion)
 	if !found {
 		log.Errorf("%s:%s not found", chartName, chartVersion)
+		err = util.WrapError(ErrChartNotFound, fmt.Errorf("no version %s of %s in %s", chartVersion, chartName, repoName))
+		return
+	}
+	if len(version.URLs) == 0 {
+		err = fmt.Errorf("%s:%s has no download url", chartName, chartVersion)
+		log.WithError(err).Error("unable to download chart")
 		return
 	}
 	// get the first URL
@@ -126,7 +140,7 @@ func (rc *RepoController) ChartDetails(repoName, chartName, chartVersion string)
 	valuesYAML := fileMap[chartName+"/values.yaml"]
 	// vrxp := regexp.MustCompile("# ")
 	// valuesYAML = vrxp.ReplaceAll(valuesYAML, []byte(""))
-	util.YAMLtoJSON(valuesYAML, &v)
+	err = util.YAMLtoJSON(valuesYAML, &v)
 	if err != nil {
 		log.WithError(err).Errorf("Unable to unmarshal values")
 		return
@@ -208,7 +222,7 @@ func findVersion(versions repo.ChartVersions, version string) (ver *repo.ChartVe
 		}
 	}
 	// if not found but version is latest, return the first item
-	if version == "latest" {
+	if !found && version == "latest" && len(versions) > 0 {
 		ver = versions[0]
 		found = true
 	}
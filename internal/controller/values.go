@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/ghodss/yaml"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/strvals"
+)
+
+// MergeValues merges the value overrides of an install or update the same way helm does. YAML
+// documents are merged first in the given order, followed by the structured values and finally
+// the helm-style --set strings (eg. "image.tag=v1,replicas=2"). Later values take precedence.
+func MergeValues(yamlDocs []string, values map[string]interface{}, set []string) (map[string]interface{}, error) {
+	merged := make(map[string]interface{})
+	for _, doc := range yamlDocs {
+		current := make(map[string]interface{})
+		if err := yaml.Unmarshal([]byte(doc), &current); err != nil {
+			return nil, err
+		}
+		merged = mergeValues(merged, current)
+	}
+	merged = mergeValues(merged, values)
+	for _, s := range set {
+		if err := strvals.ParseInto(s, merged); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+// mergeValues deep merges src into dest. nested maps are merged, everything else is replaced.
+func mergeValues(dest, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		nextMap, ok := v.(map[string]interface{})
+		if !ok {
+			dest[k] = v
+			continue
+		}
+		destMap, isMap := dest[k].(map[string]interface{})
+		if !isMap {
+			dest[k] = nextMap
+			continue
+		}
+		dest[k] = mergeValues(destMap, nextMap)
+	}
+	return dest
+}
+
+// valuesConfig converts the values to the chart config sent to tiller
+func valuesConfig(values map[string]interface{}) (*hapi_chart.Config, error) {
+	if len(values) == 0 {
+		return &hapi_chart.Config{}, nil
+	}
+	raw, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return &hapi_chart.Config{Raw: string(raw)}, nil
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		yamlDocs []string
+		values   map[string]interface{}
+		set      []string
+		want     map[string]interface{}
+	}{
+		{
+			name: "nothing",
+			want: map[string]interface{}{},
+		},
+		{
+			name:     "later yaml documents win",
+			yamlDocs: []string{"image: {repo: nginx, tag: v1}\nname: a", "image: {tag: v2}"},
+			want: map[string]interface{}{
+				"name":  "a",
+				"image": map[string]interface{}{"repo": "nginx", "tag": "v2"},
+			},
+		},
+		{
+			name:     "values win over yaml",
+			yamlDocs: []string{"image: {repo: nginx, tag: v1}"},
+			values: map[string]interface{}{
+				"image": map[string]interface{}{"tag": "v2"},
+			},
+			want: map[string]interface{}{
+				"image": map[string]interface{}{"repo": "nginx", "tag": "v2"},
+			},
+		},
+		{
+			name:     "set wins over values and yaml",
+			yamlDocs: []string{"image: {repo: nginx, tag: v1}"},
+			values: map[string]interface{}{
+				"image": map[string]interface{}{"tag": "v2"},
+			},
+			set: []string{"image.tag=v3"},
+			want: map[string]interface{}{
+				"image": map[string]interface{}{"repo": "nginx", "tag": "v3"},
+			},
+		},
+		{
+			name: "later set strings win",
+			set:  []string{"image.tag=v1,name=a", "image.tag=v2"},
+			want: map[string]interface{}{
+				"name":  "a",
+				"image": map[string]interface{}{"tag": "v2"},
+			},
+		},
+		{
+			name:     "a scalar replaces a map",
+			yamlDocs: []string{"image: {tag: v1}"},
+			values:   map[string]interface{}{"image": "nginx:v2"},
+			want:     map[string]interface{}{"image": "nginx:v2"},
+		},
+		{
+			name:     "a map replaces a scalar",
+			yamlDocs: []string{"image: nginx:v1"},
+			values: map[string]interface{}{
+				"image": map[string]interface{}{"tag": "v2"},
+			},
+			want: map[string]interface{}{
+				"image": map[string]interface{}{"tag": "v2"},
+			},
+		},
+	}
+	for _, test := range tests {
+		got, err := MergeValues(test.yamlDocs, test.values, test.set)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: MergeValues() = %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMergeValuesErrors(t *testing.T) {
+	if _, err := MergeValues([]string{"image: ["}, nil, nil); err == nil {
+		t.Error("expected an error for invalid yaml")
+	}
+	if _, err := MergeValues(nil, nil, []string{"image.tag"}); err == nil {
+		t.Error("expected an error for an invalid set string")
+	}
+}
@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"k8s.io/helm/pkg/proto/hapi/release"
+	tiller "k8s.io/helm/pkg/proto/hapi/services"
+)
+
+// release event types
+const (
+	EventCreated       = "created"
+	EventUpgraded      = "upgraded"
+	EventDeleted       = "deleted"
+	EventStatusChanged = "status-changed"
+)
+
+// watchStatusCodes are the statuses listed by the watcher. superseded is left out since only old
+// revisions have that status.
+var watchStatusCodes = []release.Status_Code{
+	release.Status_UNKNOWN,
+	release.Status_DEPLOYED,
+	release.Status_DELETED,
+	release.Status_FAILED,
+	release.Status_DELETING,
+	release.Status_PENDING_INSTALL,
+	release.Status_PENDING_UPGRADE,
+	release.Status_PENDING_ROLLBACK,
+}
+
+// ReleaseEvent is a change of a release noticed by the ReleaseWatcher
+type ReleaseEvent struct {
+	Type           string    `json:"type"`
+	Cluster        string    `json:"cluster"`
+	Name           string    `json:"name"`
+	Namespace      string    `json:"namespace"`
+	Revision       int32     `json:"revision"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previous_status,omitempty"`
+	Chart          string    `json:"chart"`
+	Time           time.Time `json:"time"`
+}
+
+// releaseState is the part of a release compared between polls
+type releaseState struct {
+	namespace string
+	revision  int32
+	status    string
+	chart     string
+}
+
+// ReleaseWatcher polls tiller for the list of releases and publishes the changes to its
+// subscribers. Polling only happens while there are subscribers, and all subscribers share
+// the same poll.
+type ReleaseWatcher struct {
+	controller  *ReleaseController
+	interval    time.Duration
+	mutex       sync.Mutex
+	subscribers map[chan *ReleaseEvent]struct{}
+	running     bool
+}
+
+// NewReleaseWatcher creates a new ReleaseWatcher polling at the given interval
+func NewReleaseWatcher(controller *ReleaseController, interval time.Duration) *ReleaseWatcher {
+	return &ReleaseWatcher{
+		controller:  controller,
+		interval:    interval,
+		subscribers: make(map[chan *ReleaseEvent]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving release events. The returned function must be called
+// to unsubscribe once the events are no longer needed.
+func (rw *ReleaseWatcher) Subscribe() (<-chan *ReleaseEvent, func()) {
+	events := make(chan *ReleaseEvent, 64)
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	rw.subscribers[events] = struct{}{}
+	if !rw.running {
+		rw.running = true
+		go rw.poll()
+	}
+	unsubscribe := func() {
+		rw.mutex.Lock()
+		defer rw.mutex.Unlock()
+		delete(rw.subscribers, events)
+	}
+	return events, unsubscribe
+}
+
+// poll lists the releases until there are no more subscribers
+func (rw *ReleaseWatcher) poll() {
+	log.Info("watching releases...")
+	previous, err := rw.snapshot()
+	if err != nil {
+		log.WithError(err).Warn("unable to get initial list of releases")
+	}
+	ticker := time.NewTicker(rw.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		rw.mutex.Lock()
+		if len(rw.subscribers) == 0 {
+			rw.running = false
+			rw.mutex.Unlock()
+			log.Info("no more release watchers, stopped polling")
+			return
+		}
+		rw.mutex.Unlock()
+
+		current, err := rw.snapshot()
+		if err != nil {
+			log.WithError(err).Warn("unable to list releases, retrying on next poll")
+			continue
+		}
+		// the first successful poll only sets the baseline
+		if previous != nil {
+			for _, event := range compareSnapshots(previous, current) {
+				event.Cluster = rw.controller.cluster
+				rw.publish(event)
+			}
+		}
+		previous = current
+	}
+}
+
+// publish sends the event to all subscribers. Slow subscribers miss events instead of blocking the others.
+func (rw *ReleaseWatcher) publish(event *ReleaseEvent) {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	for subscriber := range rw.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			log.Warnf("release watcher is too slow, dropped %s event of %s", event.Type, event.Name)
+		}
+	}
+}
+
+// snapshot returns the current state of every release, following tiller's pagination. polling
+// is shared by all subscribers, so it isn't bound to any request.
+func (rw *ReleaseWatcher) snapshot() (map[string]releaseState, error) {
+	ctx := context.Background()
+	states := make(map[string]releaseState)
+	req := &tiller.ListReleasesRequest{
+		StatusCodes: watchStatusCodes,
+	}
+	for {
+		res, err := rw.controller.ListReleases(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range res.Releases {
+			// tiller may list several revisions of the same release, keep the latest
+			if state, found := states[r.Name]; found && state.revision > r.Version {
+				continue
+			}
+			states[r.Name] = releaseState{
+				namespace: r.Namespace,
+				revision:  r.Version,
+				status:    r.GetInfo().GetStatus().GetCode().String(),
+				chart:     r.GetChart().GetMetadata().GetName(),
+			}
+		}
+		if res.Next == "" {
+			return states, nil
+		}
+		req.Offset = res.Next
+	}
+}
+
+// compareSnapshots returns the events needed to go from previous to current
+func compareSnapshots(previous, current map[string]releaseState) []*ReleaseEvent {
+	var events []*ReleaseEvent
+	now := time.Now()
+	for name, cur := range current {
+		event := &ReleaseEvent{
+			Name:      name,
+			Namespace: cur.namespace,
+			Revision:  cur.revision,
+			Status:    cur.status,
+			Chart:     cur.chart,
+			Time:      now,
+		}
+		prev, found := previous[name]
+		switch {
+		case !found:
+			event.Type = EventCreated
+		case cur.revision > prev.revision:
+			event.Type = EventUpgraded
+			event.PreviousStatus = prev.status
+		case cur.status != prev.status && cur.status == release.Status_DELETED.String():
+			event.Type = EventDeleted
+			event.PreviousStatus = prev.status
+		case cur.status != prev.status:
+			event.Type = EventStatusChanged
+			event.PreviousStatus = prev.status
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	// purged releases are no longer listed
+	for name, prev := range previous {
+		if _, found := current[name]; !found {
+			events = append(events, &ReleaseEvent{
+				Type:           EventDeleted,
+				Name:           name,
+				Namespace:      prev.namespace,
+				Revision:       prev.revision,
+				Status:         release.Status_DELETED.String(),
+				PreviousStatus: prev.status,
+				Chart:          prev.chart,
+				Time:           now,
+			})
+		}
+	}
+	return events
+}
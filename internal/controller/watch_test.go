@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/helm/pkg/proto/hapi/release"
+
+	"github.com/AcalephStorage/rudder/internal/client/fake"
+)
+
+func TestCompareSnapshots(t *testing.T) {
+	deployed := release.Status_DEPLOYED.String()
+	failed := release.Status_FAILED.String()
+	deleted := release.Status_DELETED.String()
+	previous := map[string]releaseState{
+		"unchanged": {namespace: "default", revision: 1, status: deployed, chart: "web"},
+		"upgraded":  {namespace: "default", revision: 1, status: deployed, chart: "web"},
+		"deleted":   {namespace: "default", revision: 2, status: deployed, chart: "web"},
+		"failed":    {namespace: "default", revision: 1, status: deployed, chart: "web"},
+		"purged":    {namespace: "team-a", revision: 3, status: deployed, chart: "db"},
+	}
+	current := map[string]releaseState{
+		"unchanged": {namespace: "default", revision: 1, status: deployed, chart: "web"},
+		"upgraded":  {namespace: "default", revision: 2, status: failed, chart: "web"},
+		"deleted":   {namespace: "default", revision: 2, status: deleted, chart: "web"},
+		"failed":    {namespace: "default", revision: 1, status: failed, chart: "web"},
+		"created":   {namespace: "team-a", revision: 1, status: deployed, chart: "cache"},
+	}
+
+	tests := []struct {
+		name           string
+		eventType      string
+		revision       int32
+		status         string
+		previousStatus string
+		chart          string
+	}{
+		{name: "created", eventType: EventCreated, revision: 1, status: deployed, chart: "cache"},
+		{name: "upgraded", eventType: EventUpgraded, revision: 2, status: failed, previousStatus: deployed, chart: "web"},
+		{name: "deleted", eventType: EventDeleted, revision: 2, status: deleted, previousStatus: deployed, chart: "web"},
+		{name: "failed", eventType: EventStatusChanged, revision: 1, status: failed, previousStatus: deployed, chart: "web"},
+		{name: "purged", eventType: EventDeleted, revision: 3, status: deleted, previousStatus: deployed, chart: "db"},
+	}
+	events := make(map[string]*ReleaseEvent)
+	for _, event := range compareSnapshots(previous, current) {
+		events[event.Name] = event
+	}
+	if len(events) != len(tests) {
+		t.Errorf("got %d events, want %d", len(events), len(tests))
+	}
+	for _, test := range tests {
+		event, found := events[test.name]
+		if !found {
+			t.Errorf("%s: no event", test.name)
+			continue
+		}
+		if event.Type != test.eventType || event.Revision != test.revision || event.Status != test.status || event.PreviousStatus != test.previousStatus || event.Chart != test.chart {
+			t.Errorf("%s: event = %+v, want a %s event of revision %d from %q to %s", test.name, event, test.eventType, test.revision, test.previousStatus, test.status)
+		}
+	}
+	if _, found := events["unchanged"]; found {
+		t.Error("unchanged release has an event")
+	}
+}
+
+func TestReleaseWatcher(t *testing.T) {
+	ts, rc, stop := newTestReleaseController(t)
+	defer stop()
+	ts.AddRelease(fake.NewRelease("web", "team-a", 1))
+	watcher := NewReleaseWatcher(rc, 10*time.Millisecond)
+	events, unsubscribe := watcher.Subscribe()
+	defer unsubscribe()
+
+	// wait for the initial list to be taken as the baseline
+	deadline := time.Now().Add(5 * time.Second)
+	for ts.Calls(fake.ListReleases) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the watcher never listed the releases")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	upgraded := fake.NewRelease("web", "team-a", 2)
+	ts.AddRelease(upgraded)
+
+	select {
+	case event := <-events:
+		if event.Type != EventUpgraded || event.Cluster != "test" || event.Name != "web" || event.Revision != 2 {
+			t.Errorf("event = %+v, want the upgrade of web to revision 2 in the test cluster", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
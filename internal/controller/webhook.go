@@ -0,0 +1,317 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s.io/helm/pkg/proto/hapi/release"
+)
+
+// release lifecycle events sent to webhooks
+const (
+	WebhookInstall   = "install"
+	WebhookUpgrade   = "upgrade"
+	WebhookRollback  = "rollback"
+	WebhookUninstall = "uninstall"
+	WebhookTest      = "test"
+)
+
+// delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	webhookTimeout        = 10 * time.Second
+	webhookInitialBackoff = time.Second
+	webhookMaxBackoff     = time.Minute
+	webhookDefaultRetries = 5
+	maxDeliveryLogSize    = 500
+)
+
+// Webhook is a URL that is notified of release lifecycle events. An empty Events list means all
+// events. If Secret is set, the X-Rudder-Timestamp header and the payload are signed with
+// HMAC-SHA256 in the X-Rudder-Signature header. MaxRetries defaults to 5 when unset.
+type Webhook struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	Events     []string `json:"events"`
+	MaxRetries *int     `json:"max_retries"`
+}
+
+// WebhookConfig is the webhook configuration file
+type WebhookConfig struct {
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+// WebhookEvent is the payload sent to the webhooks
+type WebhookEvent struct {
+	ID           string    `json:"id"`
+	Event        string    `json:"event"`
+	Cluster      string    `json:"cluster"`
+	Release      string    `json:"release"`
+	Namespace    string    `json:"namespace"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chart_version"`
+	Revision     int32     `json:"revision"`
+	Status       string    `json:"status"`
+	Success      bool      `json:"success"`
+	Error        string    `json:"error,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// WebhookDelivery is the delivery of an event to a single webhook
+type WebhookDelivery struct {
+	ID           string    `json:"id"`
+	EventID      string    `json:"event_id"`
+	Webhook      string    `json:"webhook"`
+	Event        string    `json:"event"`
+	Release      string    `json:"release"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	ResponseCode int       `json:"response_code"`
+	Error        string    `json:"error,omitempty"`
+	Created      time.Time `json:"created"`
+	LastAttempt  time.Time `json:"last_attempt"`
+}
+
+// WebhookController sends release lifecycle events to the configured webhooks and keeps a log of
+// the most recent deliveries.
+type WebhookController struct {
+	webhooks   []*Webhook
+	httpClient *http.Client
+	mutex      sync.RWMutex
+	deliveries []*WebhookDelivery
+}
+
+// NewWebhookController creates a new webhook controller. Webhooks without a valid URL, with
+// unknown events or with negative retries are rejected.
+func NewWebhookController(webhooks []*Webhook) (*WebhookController, error) {
+	for _, webhook := range webhooks {
+		if err := webhook.validate(); err != nil {
+			return nil, err
+		}
+		if webhook.MaxRetries == nil {
+			retries := webhookDefaultRetries
+			webhook.MaxRetries = &retries
+		}
+	}
+	return &WebhookController{
+		webhooks:   webhooks,
+		httpClient: &http.Client{Timeout: webhookTimeout},
+	}, nil
+}
+
+// validate checks the url, events and retries of the webhook
+func (w *Webhook) validate() error {
+	if w.URL == "" {
+		return fmt.Errorf("webhook %s: missing url", w.Name)
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook %s: invalid url %s", w.Name, w.URL)
+	}
+	for _, event := range w.Events {
+		switch event {
+		case WebhookInstall, WebhookUpgrade, WebhookRollback, WebhookUninstall, WebhookTest:
+		default:
+			return fmt.Errorf("webhook %s: unknown event %s", w.Name, event)
+		}
+	}
+	if w.MaxRetries != nil && *w.MaxRetries < 0 {
+		return fmt.Errorf("webhook %s: negative max_retries", w.Name)
+	}
+	return nil
+}
+
+// ListWebhooks returns the configured webhooks without their secrets
+func (wc *WebhookController) ListWebhooks() []Webhook {
+	webhooks := make([]Webhook, len(wc.webhooks))
+	for i, webhook := range wc.webhooks {
+		webhooks[i] = *webhook
+		webhooks[i].Secret = ""
+	}
+	return webhooks
+}
+
+// ListDeliveries returns the most recent deliveries, newest first. If webhook is not empty, only
+// the deliveries of that webhook are returned.
+func (wc *WebhookController) ListDeliveries(webhook string) []WebhookDelivery {
+	wc.mutex.RLock()
+	defer wc.mutex.RUnlock()
+	deliveries := make([]WebhookDelivery, 0, len(wc.deliveries))
+	for i := len(wc.deliveries) - 1; i >= 0; i-- {
+		if webhook != "" && wc.deliveries[i].Webhook != webhook {
+			continue
+		}
+		deliveries = append(deliveries, *wc.deliveries[i])
+	}
+	return deliveries
+}
+
+// Notify sends the event of the release in the cluster to every webhook subscribed to it. rel may
+// be nil if the operation failed before tiller returned a release.
+func (wc *WebhookController) Notify(cluster, eventType, name string, rel *release.Release, opErr error) {
+	if wc == nil || len(wc.webhooks) == 0 {
+		return
+	}
+	id, err := newID()
+	if err != nil {
+		log.WithError(err).Error("unable to generate webhook event id")
+		return
+	}
+	event := &WebhookEvent{
+		ID:      id,
+		Event:   eventType,
+		Cluster: cluster,
+		Release: name,
+		Success: opErr == nil,
+		Time:    time.Now(),
+	}
+	if opErr != nil {
+		event.Error = opErr.Error()
+	}
+	if rel != nil {
+		event.Namespace = rel.Namespace
+		event.Revision = rel.Version
+		event.Status = rel.GetInfo().GetStatus().GetCode().String()
+		event.Chart = rel.GetChart().GetMetadata().GetName()
+		event.ChartVersion = rel.GetChart().GetMetadata().GetVersion()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.WithError(err).Error("unable to marshal webhook event")
+		return
+	}
+
+	for _, webhook := range wc.webhooks {
+		if !webhook.subscribed(eventType) {
+			continue
+		}
+		deliveryID, err := newID()
+		if err != nil {
+			log.WithError(err).Error("unable to generate webhook delivery id")
+			continue
+		}
+		delivery := &WebhookDelivery{
+			ID:      deliveryID,
+			EventID: event.ID,
+			Webhook: webhook.Name,
+			Event:   eventType,
+			Release: name,
+			Status:  DeliveryPending,
+			Created: event.Time,
+		}
+		wc.addDelivery(delivery)
+		go wc.deliver(webhook, delivery, payload)
+	}
+}
+
+// subscribed checks if the webhook wants the event
+func (w *Webhook) subscribed(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver posts the payload to the webhook, retrying with exponential backoff
+func (wc *WebhookController) deliver(webhook *Webhook, delivery *WebhookDelivery, payload []byte) {
+	backoff := webhookInitialBackoff
+	maxRetries := *webhook.MaxRetries
+	for attempt := 1; attempt <= maxRetries+1; attempt++ {
+		code, err := wc.post(webhook, delivery, payload)
+
+		wc.mutex.Lock()
+		delivery.Attempts = attempt
+		delivery.LastAttempt = time.Now()
+		delivery.ResponseCode = code
+		delivery.Error = ""
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Status = DeliveryDelivered
+		}
+		wc.mutex.Unlock()
+
+		if err == nil {
+			return
+		}
+		log.WithError(err).Warnf("unable to deliver %s event to webhook %s (attempt %d)", delivery.Event, webhook.Name, attempt)
+		if attempt <= maxRetries {
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > webhookMaxBackoff {
+				backoff = webhookMaxBackoff
+			}
+		}
+	}
+
+	wc.mutex.Lock()
+	delivery.Status = DeliveryFailed
+	wc.mutex.Unlock()
+	log.Errorf("giving up delivering %s event to webhook %s", delivery.Event, webhook.Name)
+}
+
+// post sends a single request to the webhook. any non 2xx response is an error
+func (wc *WebhookController) post(webhook *Webhook, delivery *WebhookDelivery, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Rudder-Event", delivery.Event)
+	req.Header.Set("X-Rudder-Delivery", delivery.ID)
+	if webhook.Secret != "" {
+		// the timestamp is signed so a captured delivery can't be replayed later
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Rudder-Timestamp", timestamp)
+		req.Header.Set("X-Rudder-Signature", "sha256="+signPayload(webhook.Secret, timestamp, payload))
+	}
+	res, err := wc.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// addDelivery adds the delivery to the log, dropping the oldest ones if the log is full
+func (wc *WebhookController) addDelivery(delivery *WebhookDelivery) {
+	wc.mutex.Lock()
+	defer wc.mutex.Unlock()
+	wc.deliveries = append(wc.deliveries, delivery)
+	if len(wc.deliveries) > maxDeliveryLogSize {
+		wc.deliveries = wc.deliveries[len(wc.deliveries)-maxDeliveryLogSize:]
+	}
+}
+
+// signPayload returns the hex encoded HMAC-SHA256 of "<timestamp>.<payload>"
+func signPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestNewWebhookControllerValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook Webhook
+		valid   bool
+	}{
+		{name: "valid", webhook: Webhook{Name: "a", URL: "https://example.com/hook"}, valid: true},
+		{name: "valid events", webhook: Webhook{Name: "a", URL: "http://example.com", Events: []string{WebhookInstall, WebhookTest}}, valid: true},
+		{name: "empty url", webhook: Webhook{Name: "a"}},
+		{name: "relative url", webhook: Webhook{Name: "a", URL: "/hook"}},
+		{name: "unsupported scheme", webhook: Webhook{Name: "a", URL: "ftp://example.com"}},
+		{name: "unknown event", webhook: Webhook{Name: "a", URL: "https://example.com", Events: []string{"installed"}}},
+		{name: "negative retries", webhook: Webhook{Name: "a", URL: "https://example.com", MaxRetries: intPtr(-1)}},
+	}
+	for _, test := range tests {
+		webhook := test.webhook
+		_, err := NewWebhookController([]*Webhook{&webhook})
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestNewWebhookControllerRetries(t *testing.T) {
+	unset := &Webhook{Name: "unset", URL: "https://example.com"}
+	zero := &Webhook{Name: "zero", URL: "https://example.com", MaxRetries: intPtr(0)}
+	if _, err := NewWebhookController([]*Webhook{unset, zero}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *unset.MaxRetries != webhookDefaultRetries {
+		t.Errorf("unset max_retries = %d, want %d", *unset.MaxRetries, webhookDefaultRetries)
+	}
+	if *zero.MaxRetries != 0 {
+		t.Errorf("zero max_retries = %d, want 0", *zero.MaxRetries)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		mutex.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{Name: "hook", URL: server.URL, Secret: "s3cr3t", MaxRetries: intPtr(0)}
+	wc, err := NewWebhookController([]*Webhook{webhook})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delivery := &WebhookDelivery{ID: "delivery", Webhook: webhook.Name, Event: WebhookInstall, Status: DeliveryPending}
+	wc.addDelivery(delivery)
+	wc.deliver(webhook, delivery, []byte(`{"event":"install"}`))
+
+	deliveries := wc.ListDeliveries("")
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	if deliveries[0].Status != DeliveryFailed || deliveries[0].Attempts != 1 || deliveries[0].ResponseCode != http.StatusInternalServerError {
+		t.Errorf("delivery = %+v, want a single failed attempt", deliveries[0])
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1 with max_retries 0", len(requests))
+	}
+	timestamp := requests[0].Header.Get("X-Rudder-Timestamp")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(unix, 0)) > time.Minute {
+		t.Errorf("invalid timestamp %q", timestamp)
+	}
+	want := "sha256=" + signPayload("s3cr3t", timestamp, bodies[0])
+	if signature := requests[0].Header.Get("X-Rudder-Signature"); signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+	if signPayload("s3cr3t", "0", bodies[0]) == signPayload("s3cr3t", timestamp, bodies[0]) {
+		t.Error("the signature doesn't cover the timestamp")
+	}
+}
+
+func TestWebhookNotify(t *testing.T) {
+	events := make(chan WebhookEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event WebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		events <- event
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{Name: "hook", URL: server.URL, MaxRetries: intPtr(0)}
+	wc, err := NewWebhookController([]*Webhook{webhook})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wc.Notify("staging", WebhookTest, "web", nil, errors.New("1 test(s) failed"))
+
+	select {
+	case event := <-events:
+		if event.Cluster != "staging" || event.Event != WebhookTest || event.Release != "web" || event.Success || event.Error != "1 test(s) failed" {
+			t.Errorf("event = %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
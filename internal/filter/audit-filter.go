@@ -0,0 +1,171 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emicklei/go-restful"
+
+	"github.com/AcalephStorage/rudder/internal/controller"
+)
+
+// auditedBody are the request body fields included in the audit record. version is left raw since
+// it's a chart version on install and update but a revision on rollback.
+type auditedBody struct {
+	Name       string                 `json:"name"`
+	Namespace  string                 `json:"namespace"`
+	Repo       string                 `json:"repo"`
+	Chart      string                 `json:"chart"`
+	Version    json.RawMessage        `json:"version"`
+	ValuesYAML []string               `json:"values_yaml"`
+	Values     map[string]interface{} `json:"values"`
+	Set        []string               `json:"set"`
+}
+
+// OperationIDAttribute is the request attribute holding the id of the operation an async request
+// was queued as
+const OperationIDAttribute = "rudder.operation-id"
+
+// AuditFilter provides go-restful route filters recording mutating calls to the audit log
+type AuditFilter struct {
+	controller       *controller.AuditController
+	operations       *controller.OperationController
+	releaseNamespace NamespaceResolver
+	trustedProxies   []*net.IPNet
+}
+
+// NewAuditFilter returns an audit filter writing to the audit controller. The outcome of async
+// requests is recorded once their operation finishes. releaseNamespace is used to find the
+// namespace of an existing release, and X-Forwarded-For is only used for requests from
+// trustedProxies.
+func NewAuditFilter(controller *controller.AuditController, operations *controller.OperationController, releaseNamespace NamespaceResolver, trustedProxies []*net.IPNet) *AuditFilter {
+	return &AuditFilter{
+		controller:       controller,
+		operations:       operations,
+		releaseNamespace: releaseNamespace,
+		trustedProxies:   trustedProxies,
+	}
+}
+
+// Audit returns a route filter recording the operation. the route's `release` path parameter is
+// used as the release name if present, and its namespace is resolved the same way the authz
+// filter does.
+func (af *AuditFilter) Audit(operation string) restful.FilterFunction {
+	return func(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+		if af == nil {
+			chain.ProcessFilter(req, res)
+			return
+		}
+		body := readAuditedBody(req)
+		principal := RequestPrincipal(req)
+		record := &controller.AuditRecord{
+			Time:      time.Now(),
+			User:      principal.User,
+			Groups:    principal.Groups,
+			SourceIP:  SourceIP(req.Request, af.trustedProxies),
+			Operation: operation,
+			Method:    req.Request.Method,
+			Path:      req.Request.URL.Path,
+			Cluster:   req.PathParameter("cluster"),
+			Release:   req.PathParameter("release"),
+			Chart:     body.Chart,
+		}
+		// the call is recorded even if the release can't be looked up
+		record.Namespace, _, _ = RequestNamespace(req, af.releaseNamespace, body.Namespace)
+		if record.Namespace == "" {
+			record.Namespace = body.Namespace
+		}
+		if record.Release == "" {
+			record.Release = body.Name
+		}
+		if body.Repo != "" && body.Chart != "" {
+			record.Chart = fmt.Sprintf("%s/%s", body.Repo, body.Chart)
+		}
+		if len(body.Version) > 0 {
+			var version interface{}
+			if err := json.Unmarshal(body.Version, &version); err == nil {
+				record.Version = fmt.Sprint(version)
+			}
+		}
+		if len(body.ValuesYAML) > 0 || len(body.Values) > 0 || len(body.Set) > 0 {
+			record.ValuesHash = controller.ValuesHash(map[string]interface{}{
+				"values_yaml": body.ValuesYAML,
+				"values":      body.Values,
+				"set":         body.Set,
+			})
+		}
+
+		chain.ProcessFilter(req, res)
+
+		record.StatusCode = res.StatusCode()
+		record.Duration = time.Since(record.Time).String()
+		switch {
+		case record.StatusCode == http.StatusAccepted:
+			record.Outcome = controller.AuditAccepted
+		case record.StatusCode >= 400:
+			record.Outcome = controller.AuditFailure
+		default:
+			record.Outcome = controller.AuditSuccess
+		}
+		if id, queued := req.Attribute(OperationIDAttribute).(string); queued && record.Outcome == controller.AuditAccepted {
+			record.OperationID = id
+		}
+		af.controller.Record(record)
+		if record.OperationID != "" {
+			af.recordOutcome(*record)
+		}
+	}
+}
+
+// recordOutcome records the outcome of an async call once its operation has finished
+func (af *AuditFilter) recordOutcome(accepted controller.AuditRecord) {
+	err := af.operations.OnFinish(accepted.OperationID, func(op controller.Operation) {
+		record := accepted
+		record.Time = op.Finished
+		record.Duration = op.Finished.Sub(accepted.Time).String()
+		record.Outcome = controller.AuditSuccess
+		if op.Status == controller.OperationFailed {
+			record.Outcome = controller.AuditFailure
+			record.Error = op.Error
+		}
+		af.controller.Record(&record)
+	})
+	if err != nil {
+		log.WithError(err).Errorf("unable to record the outcome of operation %s", accepted.OperationID)
+	}
+}
+
+// readAuditedBody parses the audited fields of the request body
+func readAuditedBody(req *restful.Request) *auditedBody {
+	body := &auditedBody{}
+	data := peekBody(req)
+	if len(data) == 0 {
+		return body
+	}
+	if err := json.Unmarshal(data, body); err != nil {
+		log.WithError(err).Debug("unable to parse request body for the audit record")
+	}
+	return body
+}
+
+// peekBody reads the request body and puts it back for the next filter or route
+func peekBody(req *restful.Request) []byte {
+	if req.Request.Body == nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(req.Request.Body)
+	req.Request.Body.Close()
+	req.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		log.WithError(err).Debug("unable to read request body")
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,175 @@
+package filter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emicklei/go-restful"
+
+	"github.com/AcalephStorage/rudder/internal/client"
+	"github.com/AcalephStorage/rudder/internal/controller"
+	"github.com/AcalephStorage/rudder/internal/util"
+)
+
+// authzBody are the request body fields used to scope the authorization
+type authzBody struct {
+	Namespace string `json:"namespace"`
+	Repo      string `json:"repo"`
+}
+
+// AuthzFilter provides go-restful route filters authorizing the caller against the policies. It
+// must run after the auth filter.
+type AuthzFilter struct {
+	controller       *controller.AuthzController
+	releaseNamespace NamespaceResolver
+	defaultCluster   string
+}
+
+// NewAuthzFilter returns an authorization filter. releaseNamespace is used to find the namespace of
+// an existing release in a cluster. defaultCluster is the cluster of the routes without a cluster.
+func NewAuthzFilter(controller *controller.AuthzController, releaseNamespace NamespaceResolver, defaultCluster string) *AuthzFilter {
+	return &AuthzFilter{
+		controller:       controller,
+		releaseNamespace: releaseNamespace,
+		defaultCluster:   defaultCluster,
+	}
+}
+
+// Authorize returns a route filter for release routes. The cluster is the `cluster` path parameter,
+// or the default cluster. The namespace is the one of the release in the `release` path parameter,
+// the `namespace` query parameter for list, or the request body namespace for new releases. The
+// repo is taken from the request body. Upgrading a release that doesn't exist needs the install
+// verb instead.
+func (af *AuthzFilter) Authorize(verb string) restful.FilterFunction {
+	return func(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+		if af == nil {
+			chain.ProcessFilter(req, res)
+			return
+		}
+		body := readAuthzBody(req)
+		accessReq := af.accessRequest(req, verb)
+		accessReq.Cluster = af.cluster(req.PathParameter("cluster"))
+		accessReq.Namespaced = true
+		accessReq.Repo = body.Repo
+
+		if verb == controller.VerbList {
+			accessReq.Namespace = req.QueryParameter("namespace")
+			af.authorize(accessReq, req, res, chain)
+			return
+		}
+		// a release that doesn't exist yet will be installed in the requested namespace
+		namespace, exists, err := RequestNamespace(req, af.releaseNamespace, body.Namespace)
+		// unknown clusters are answered by the route
+		if err == controller.ErrClusterNotFound {
+			chain.ProcessFilter(req, res)
+			return
+		}
+		// tiller is known to be down, so the namespace can't be found
+		if coe, open := util.RootCause(err).(*client.CircuitOpenError); open {
+			body := ErrorBody{Code: "tiller_unavailable", Message: "unable to authorize request", Error: err.Error()}
+			WriteError(res, http.StatusServiceUnavailable, body, coe.RetryAfter)
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("unable to authorize request")
+			body := ErrorBody{Code: "internal_error", Message: "unable to authorize request", Error: err.Error()}
+			WriteError(res, http.StatusInternalServerError, body, 0)
+			return
+		}
+		accessReq.Namespace = namespace
+		// upgrading a release that doesn't exist installs it
+		if !exists && req.PathParameter("release") != "" && verb == controller.VerbUpgrade {
+			accessReq.Verb = controller.VerbInstall
+		}
+		af.authorize(accessReq, req, res, chain)
+	}
+}
+
+// AuthorizeRepo returns a route filter for repo routes, scoped by the `repo` path parameter
+func (af *AuthzFilter) AuthorizeRepo(verb string) restful.FilterFunction {
+	return func(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+		if af == nil {
+			chain.ProcessFilter(req, res)
+			return
+		}
+		accessReq := af.accessRequest(req, verb)
+		accessReq.Repo = req.PathParameter("repo")
+		af.authorize(accessReq, req, res, chain)
+	}
+}
+
+// AuthorizeGlobal returns a route filter for routes that aren't scoped to a namespace or repo, eg.
+// the audit log with the admin verb
+func (af *AuthzFilter) AuthorizeGlobal(verb string) restful.FilterFunction {
+	return func(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+		if af == nil {
+			chain.ProcessFilter(req, res)
+			return
+		}
+		af.authorize(af.accessRequest(req, verb), req, res, chain)
+	}
+}
+
+// AuthorizeOperation returns a route filter for operation routes. Getting an operation needs the
+// get verb in the cluster and namespace of its release. Unknown operations are answered by the
+// route.
+func (af *AuthzFilter) AuthorizeOperation(operations *controller.OperationController) restful.FilterFunction {
+	return func(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+		if af == nil {
+			chain.ProcessFilter(req, res)
+			return
+		}
+		op, err := operations.Get(req.PathParameter("id"))
+		if err != nil {
+			chain.ProcessFilter(req, res)
+			return
+		}
+		accessReq := af.accessRequest(req, controller.VerbGet)
+		accessReq.Cluster = af.cluster(op.Cluster)
+		accessReq.Namespaced = true
+		accessReq.Namespace = op.Namespace
+		af.authorize(accessReq, req, res, chain)
+	}
+}
+
+// accessRequest creates the access request of the caller
+func (af *AuthzFilter) accessRequest(req *restful.Request, verb string) *controller.AccessRequest {
+	principal := RequestPrincipal(req)
+	return &controller.AccessRequest{
+		User:   principal.User,
+		Groups: principal.Groups,
+		Verb:   verb,
+	}
+}
+
+// cluster returns the cluster, or the default cluster if empty
+func (af *AuthzFilter) cluster(cluster string) string {
+	if cluster == "" {
+		return af.defaultCluster
+	}
+	return cluster
+}
+
+// authorize continues the chain if the request is allowed, or responds with 403 and the reason
+func (af *AuthzFilter) authorize(accessReq *controller.AccessRequest, req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+	if err := af.controller.Authorize(accessReq); err != nil {
+		log.Warnf("denied %s %s: %v", req.Request.Method, req.Request.URL.Path, err)
+		WriteError(res, http.StatusForbidden, ErrorBody{Code: "forbidden", Message: err.Error()}, 0)
+		return
+	}
+	chain.ProcessFilter(req, res)
+}
+
+// readAuthzBody parses the fields of the request body used for authorization
+func readAuthzBody(req *restful.Request) *authzBody {
+	body := &authzBody{}
+	data := peekBody(req)
+	if len(data) == 0 {
+		return body
+	}
+	if err := json.Unmarshal(data, body); err != nil {
+		log.WithError(err).Debug("unable to parse request body for authorization")
+	}
+	return body
+}
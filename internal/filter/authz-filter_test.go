@@ -0,0 +1,223 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emicklei/go-restful"
+	"golang.org/x/net/context"
+
+	"github.com/AcalephStorage/rudder/internal/client"
+	"github.com/AcalephStorage/rudder/internal/controller"
+)
+
+// testNamespaces are the releases known to the test namespace resolver
+var testNamespaces = map[string]string{
+	"web": "team-a",
+	"db":  "kube-system",
+}
+
+// testResolver finds the namespace of the test releases. The "down" release fails as if tiller
+// was unavailable and the "broken" release with an unexpected error.
+func testResolver(ctx context.Context, cluster, name string) (string, error) {
+	switch {
+	case cluster == "missing":
+		return "", controller.ErrClusterNotFound
+	case name == "down":
+		return "", &client.CircuitOpenError{Address: "tiller", RetryAfter: 10 * time.Second}
+	case name == "broken":
+		return "", errors.New("boom")
+	}
+	return testNamespaces[name], nil
+}
+
+// withUser is a container filter setting the principal, as the identity filter would
+func withUser(user string, groups ...string) restful.FilterFunction {
+	return func(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+		req.SetAttribute(principalAttribute, &Principal{User: user, Groups: groups})
+		chain.ProcessFilter(req, res)
+	}
+}
+
+// newTestAuthzFilter returns an authz filter with a team-a policy for alice, a dev clusters policy
+// for dave and an admin group. The default cluster is prod.
+func newTestAuthzFilter(t *testing.T) *AuthzFilter {
+	authz, err := controller.NewAuthzController([]*controller.Policy{
+		{
+			Name:       "team-a",
+			Users:      []string{"alice"},
+			Verbs:      []string{controller.VerbList, controller.VerbGet, controller.VerbInstall, controller.VerbUpgrade},
+			Namespaces: []string{"team-a"},
+		},
+		{
+			Name:       "dev",
+			Users:      []string{"dave"},
+			Verbs:      []string{controller.VerbList, controller.VerbInstall, controller.VerbDelete},
+			Clusters:   []string{"dev-*"},
+			Namespaces: []string{"team-*"},
+		},
+		{
+			Name:   "admins",
+			Groups: []string{"admins"},
+			Verbs:  []string{"*"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("invalid policies: %v", err)
+	}
+	return NewAuthzFilter(authz, testResolver, "prod")
+}
+
+// serve sends the request through a container with the filter and returns the response
+func serve(user string, groups []string, method, path, body string, register func(ws *restful.WebService, handler restful.RouteFunction)) *httptest.ResponseRecorder {
+	container := restful.NewContainer()
+	container.Filter(withUser(user, groups...))
+	ws := new(restful.WebService)
+	ws.Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+	register(ws, func(req *restful.Request, res *restful.Response) {
+		res.WriteHeader(http.StatusOK)
+	})
+	container.Add(ws)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set("Accept", restful.MIME_JSON)
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestAuthorize(t *testing.T) {
+	af := newTestAuthzFilter(t)
+	releaseRoutes := func(ws *restful.WebService, handler restful.RouteFunction) {
+		ws.Route(ws.GET("/clusters/{cluster}/releases").To(handler).Filter(af.Authorize(controller.VerbList)))
+		ws.Route(ws.POST("/clusters/{cluster}/releases").To(handler).Filter(af.Authorize(controller.VerbInstall)))
+		ws.Route(ws.PUT("/clusters/{cluster}/releases/{release}").To(handler).Filter(af.Authorize(controller.VerbUpgrade)))
+		ws.Route(ws.DELETE("/clusters/{cluster}/releases/{release}").To(handler).Filter(af.Authorize(controller.VerbDelete)))
+	}
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"list in own namespace", "alice", http.MethodGet, "/clusters/a/releases?namespace=team-a", "", http.StatusOK},
+		{"list in all namespaces", "alice", http.MethodGet, "/clusters/a/releases", "", http.StatusForbidden},
+		{"install in own namespace", "alice", http.MethodPost, "/clusters/a/releases", `{"namespace":"team-a"}`, http.StatusOK},
+		{"install in another namespace", "alice", http.MethodPost, "/clusters/a/releases", `{"namespace":"kube-system"}`, http.StatusForbidden},
+		{"install in the default namespace", "alice", http.MethodPost, "/clusters/a/releases", `{}`, http.StatusForbidden},
+		{"upgrade own release", "alice", http.MethodPut, "/clusters/a/releases/web", `{"namespace":"kube-system"}`, http.StatusOK},
+		{"upgrade another release", "alice", http.MethodPut, "/clusters/a/releases/db", `{"namespace":"team-a"}`, http.StatusForbidden},
+		{"upgrade a missing release installs it", "alice", http.MethodPut, "/clusters/a/releases/new", `{"namespace":"team-a"}`, http.StatusOK},
+		{"upgrade a missing release elsewhere", "alice", http.MethodPut, "/clusters/a/releases/new", `{"namespace":"kube-system"}`, http.StatusForbidden},
+		{"delete without the verb", "alice", http.MethodDelete, "/clusters/a/releases/web", "", http.StatusForbidden},
+		{"unknown user", "mallory", http.MethodGet, "/clusters/a/releases?namespace=team-a", "", http.StatusForbidden},
+		{"unknown cluster is left to the route", "alice", http.MethodDelete, "/clusters/missing/releases/web", "", http.StatusOK},
+		{"tiller unavailable", "alice", http.MethodPut, "/clusters/a/releases/down", "", http.StatusServiceUnavailable},
+		{"lookup failure", "alice", http.MethodPut, "/clusters/a/releases/broken", "", http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		recorder := serve(test.user, nil, test.method, test.path, test.body, releaseRoutes)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d, want %d: %s", test.name, recorder.Code, test.status, recorder.Body)
+		}
+	}
+
+	recorder := serve("alice", nil, http.MethodPut, "/clusters/a/releases/down", "", releaseRoutes)
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "10" {
+		t.Errorf("Retry-After = %s, want 10", retryAfter)
+	}
+}
+
+func TestAuthorizeCluster(t *testing.T) {
+	af := newTestAuthzFilter(t)
+	routes := func(ws *restful.WebService, handler restful.RouteFunction) {
+		ws.Route(ws.POST("/releases").To(handler).Filter(af.Authorize(controller.VerbInstall)))
+		ws.Route(ws.POST("/clusters/{cluster}/releases").To(handler).Filter(af.Authorize(controller.VerbInstall)))
+		ws.Route(ws.DELETE("/clusters/{cluster}/releases/{release}").To(handler).Filter(af.Authorize(controller.VerbDelete)))
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"install in a dev cluster", http.MethodPost, "/clusters/dev-1/releases", `{"namespace":"team-a"}`, http.StatusOK},
+		{"install in another cluster", http.MethodPost, "/clusters/prod/releases", `{"namespace":"team-a"}`, http.StatusForbidden},
+		{"install in the default cluster", http.MethodPost, "/releases", `{"namespace":"team-a"}`, http.StatusForbidden},
+		{"delete in a dev cluster", http.MethodDelete, "/clusters/dev-1/releases/web", "", http.StatusOK},
+		{"delete in another cluster", http.MethodDelete, "/clusters/prod/releases/web", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		recorder := serve("dave", nil, test.method, test.path, test.body, routes)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d, want %d: %s", test.name, recorder.Code, test.status, recorder.Body)
+		}
+	}
+	// policies without clusters allow every cluster
+	if recorder := serve("alice", nil, http.MethodPost, "/clusters/prod/releases", `{"namespace":"team-a"}`, routes); recorder.Code != http.StatusOK {
+		t.Errorf("install by alice: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
+
+func TestAuthorizeGlobal(t *testing.T) {
+	af := newTestAuthzFilter(t)
+	routes := func(ws *restful.WebService, handler restful.RouteFunction) {
+		ws.Route(ws.GET("/audit").To(handler).Filter(af.AuthorizeGlobal(controller.VerbAdmin)))
+		ws.Route(ws.GET("/clusters").To(handler).Filter(af.AuthorizeGlobal(controller.VerbList)))
+	}
+	if recorder := serve("alice", nil, http.MethodGet, "/audit", "", routes); recorder.Code != http.StatusForbidden {
+		t.Errorf("audit by alice: status = %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+	if recorder := serve("bob", []string{"admins"}, http.MethodGet, "/audit", "", routes); recorder.Code != http.StatusOK {
+		t.Errorf("audit by an admin: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if recorder := serve("alice", nil, http.MethodGet, "/clusters", "", routes); recorder.Code != http.StatusOK {
+		t.Errorf("clusters by alice: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
+
+func TestAuthorizeOperation(t *testing.T) {
+	af := newTestAuthzFilter(t)
+	operations := controller.NewOperationController(1, 10, time.Minute)
+	defer operations.Stop()
+	run := func() (interface{}, error) { return nil, nil }
+	own, err := operations.Submit("install", "", "team-a", "web", run)
+	if err != nil {
+		t.Fatalf("unable to submit: %v", err)
+	}
+	other, err := operations.Submit("install", "", "kube-system", "db", run)
+	if err != nil {
+		t.Fatalf("unable to submit: %v", err)
+	}
+	routes := func(ws *restful.WebService, handler restful.RouteFunction) {
+		ws.Route(ws.GET("/operations/{id}").To(handler).Filter(af.AuthorizeOperation(operations)))
+	}
+	if recorder := serve("alice", nil, http.MethodGet, "/operations/"+own.ID, "", routes); recorder.Code != http.StatusOK {
+		t.Errorf("own operation: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if recorder := serve("alice", nil, http.MethodGet, "/operations/"+other.ID, "", routes); recorder.Code != http.StatusForbidden {
+		t.Errorf("other operation: status = %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+	if recorder := serve("alice", nil, http.MethodGet, "/operations/missing", "", routes); recorder.Code != http.StatusOK {
+		t.Errorf("missing operation is left to the route: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
+
+func TestAuthzFilterDisabled(t *testing.T) {
+	var af *AuthzFilter
+	routes := func(ws *restful.WebService, handler restful.RouteFunction) {
+		ws.Route(ws.GET("/audit").To(handler).Filter(af.AuthorizeGlobal(controller.VerbAdmin)))
+	}
+	if recorder := serve("anonymous", nil, http.MethodGet, "/audit", "", routes); recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,39 @@
+package filter
+
+import (
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emicklei/go-restful"
+)
+
+// ErrorBody is the JSON body of error responses. Message describes what rudder was doing, while
+// Error is the underlying, usually tiller's, error.
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Error     string `json:"error,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// WriteError writes the error body along with the request ID. Retry-After is set if retryAfter is
+// positive. Filters and resources use it so every error response looks the same.
+func WriteError(res *restful.Response, httpStatus int, body ErrorBody, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		res.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	}
+	body.RequestID = res.Header().Get(RequestIDHeader)
+	if err := res.WriteHeaderAndEntity(httpStatus, body); err != nil {
+		log.WithError(err).Error("unable to write error")
+	}
+}
+
+// retryAfterSeconds returns the Retry-After header value of the wait, rounded up to a second
+func retryAfterSeconds(wait time.Duration) string {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
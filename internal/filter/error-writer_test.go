@@ -0,0 +1,58 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emicklei/go-restful"
+)
+
+func TestWriteError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	res := restful.NewResponse(recorder)
+	res.SetRequestAccepts(restful.MIME_JSON)
+	res.Header().Set(RequestIDHeader, "request")
+	WriteError(res, http.StatusServiceUnavailable, ErrorBody{Code: "tiller_unavailable", Message: "unable to list releases", Error: "down"}, 1500*time.Millisecond)
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Retry-After = %s, want 2", retryAfter)
+	}
+	var body ErrorBody
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unable to parse body: %v", err)
+	}
+	want := ErrorBody{Code: "tiller_unavailable", Message: "unable to list releases", Error: "down", RequestID: "request"}
+	if body != want {
+		t.Errorf("body = %+v, want %+v", body, want)
+	}
+
+	recorder = httptest.NewRecorder()
+	res = restful.NewResponse(recorder)
+	res.SetRequestAccepts(restful.MIME_JSON)
+	WriteError(res, http.StatusForbidden, ErrorBody{Code: "forbidden", Message: "denied"}, 0)
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "" {
+		t.Errorf("Retry-After = %s, want none", retryAfter)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	for wait, want := range map[time.Duration]string{
+		0:                        "1",
+		time.Millisecond:         "1",
+		time.Second:              "1",
+		time.Second + 1:          "2",
+		30 * time.Second:         "30",
+		29500 * time.Millisecond: "30",
+	} {
+		if got := retryAfterSeconds(wait); got != want {
+			t.Errorf("retryAfterSeconds(%v) = %s, want %s", wait, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+)
+
+const (
+	// anonymous is the user of requests without credentials, eg. when running with --insecure
+	anonymous = "anonymous"
+
+	principalAttribute = "rudder.principal"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// jwtClaims are the OIDC claims used to identify the caller
+type jwtClaims struct {
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email"`
+	PreferredName string   `json:"preferred_username"`
+	Groups        []string `json:"groups"`
+}
+
+// IdentityFilter wraps the auth filter and keeps the caller of the requests it authenticated
+type IdentityFilter struct {
+	authenticate restful.FilterFunction
+	exceptions   []string
+	basicAuth    bool
+	oidc         bool
+}
+
+// NewIdentityFilter returns a filter running authenticate, the auth filter, and identifying the
+// callers it lets through. Only the credentials of the enabled auth methods are used, and paths
+// starting with one of the exceptions are left anonymous.
+func NewIdentityFilter(authenticate restful.FilterFunction, exceptions []string, basicAuth, oidc bool) *IdentityFilter {
+	return &IdentityFilter{
+		authenticate: authenticate,
+		exceptions:   exceptions,
+		basicAuth:    basicAuth,
+		oidc:         oidc,
+	}
+}
+
+// Identify runs the auth filter, and only once it has verified the credentials, decodes the caller
+// from them for RequestPrincipal. The rest of the chain runs only if the auth filter allows it.
+func (idf *IdentityFilter) Identify(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+	authChain := &restful.FilterChain{
+		Target: func(req *restful.Request, res *restful.Response) {
+			if !idf.isException(req.Request.URL.Path) {
+				req.SetAttribute(principalAttribute, idf.credentialsPrincipal(req.Request))
+			}
+			chain.ProcessFilter(req, res)
+		},
+	}
+	idf.authenticate(req, res, authChain)
+}
+
+// isException checks if the path doesn't need authentication
+func (idf *IdentityFilter) isException(path string) bool {
+	for _, exception := range idf.exceptions {
+		if strings.HasPrefix(path, exception) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestPrincipal returns the caller of the request as verified by the identity filter. Requests
+// it didn't authenticate, eg. when running with --insecure, are anonymous.
+func RequestPrincipal(req *restful.Request) *Principal {
+	if principal, ok := req.Attribute(principalAttribute).(*Principal); ok {
+		return principal
+	}
+	return &Principal{User: anonymous}
+}
+
+// credentialsPrincipal decodes the caller from the credentials. They must have been verified
+// already: the username of basic auth, or the email (falling back to preferred_username and sub)
+// and groups claims of an OIDC token.
+func (idf *IdentityFilter) credentialsPrincipal(req *http.Request) *Principal {
+	if username, _, ok := req.BasicAuth(); ok && idf.basicAuth {
+		return &Principal{User: username}
+	}
+	authHeader := req.Header.Get("Authorization")
+	if !idf.oidc || !strings.HasPrefix(authHeader, "Bearer ") {
+		return &Principal{User: anonymous}
+	}
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return &Principal{User: anonymous}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return &Principal{User: anonymous}
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return &Principal{User: anonymous}
+	}
+	user := claims.Email
+	if user == "" {
+		user = claims.PreferredName
+	}
+	if user == "" {
+		user = claims.Subject
+	}
+	return &Principal{User: user, Groups: claims.Groups}
+}
+
+// ParseTrustedProxies parses a comma separated list of proxy IPs and CIDRs
+func ParseTrustedProxies(proxies string) ([]*net.IPNet, error) {
+	var trusted []*net.IPNet
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %s", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %s", proxy)
+		}
+		trusted = append(trusted, ipNet)
+	}
+	return trusted, nil
+}
+
+// SourceIP returns the IP of the caller. X-Forwarded-For is only used for requests coming from a
+// trusted proxy, and is read from the right up to the first entry that isn't a trusted proxy, since
+// anything further left could have been sent by the caller.
+func SourceIP(req *http.Request, trustedProxies []*net.IPNet) string {
+	source := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+	if !isTrustedProxy(source, trustedProxies) {
+		return source
+	}
+	forwarded := strings.Split(strings.Join(req.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip == "" {
+			continue
+		}
+		source = ip
+		if !isTrustedProxy(ip, trustedProxies) {
+			break
+		}
+	}
+	return source
+}
+
+// isTrustedProxy checks if the ip is one of the trusted proxies
+func isTrustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
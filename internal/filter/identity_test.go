@@ -0,0 +1,201 @@
+package filter
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emicklei/go-restful"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies(" 10.0.0.1, 192.168.0.0/16,,::1 ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(proxies) != 3 {
+		t.Fatalf("got %d proxies, want 3", len(proxies))
+	}
+	for ip, want := range map[string]bool{
+		"10.0.0.1":    true,
+		"10.0.0.2":    false,
+		"192.168.3.4": true,
+		"::1":         true,
+		"::2":         false,
+	} {
+		if got := isTrustedProxy(ip, proxies); got != want {
+			t.Errorf("isTrustedProxy(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0", "10.0.0.0/33", "proxy"} {
+		if _, err := ParseTrustedProxies(invalid); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		trusted    []*net.IPNet
+		want       string
+	}{
+		{name: "remote address", remoteAddr: "1.2.3.4:5678", want: "1.2.3.4"},
+		{name: "remote address without port", remoteAddr: "1.2.3.4", want: "1.2.3.4"},
+		{name: "untrusted forwarded for", remoteAddr: "1.2.3.4:5678", forwarded: []string{"5.6.7.8"}, want: "1.2.3.4"},
+		{name: "no trusted proxies", remoteAddr: "10.0.0.1:5678", forwarded: []string{"5.6.7.8"}, want: "10.0.0.1"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:5678", forwarded: []string{"5.6.7.8"}, trusted: proxies, want: "5.6.7.8"},
+		{name: "spoofed entries are skipped", remoteAddr: "10.0.0.1:5678", forwarded: []string{"9.9.9.9, 5.6.7.8"}, trusted: proxies, want: "5.6.7.8"},
+		{name: "chained proxies", remoteAddr: "10.0.0.1:5678", forwarded: []string{"9.9.9.9, 5.6.7.8", "10.0.0.2"}, trusted: proxies, want: "5.6.7.8"},
+		{name: "only proxies", remoteAddr: "10.0.0.1:5678", forwarded: []string{"10.0.0.3, 10.0.0.2"}, trusted: proxies, want: "10.0.0.3"},
+		{name: "empty forwarded for", remoteAddr: "10.0.0.1:5678", trusted: proxies, want: "10.0.0.1"},
+	}
+	for _, test := range tests {
+		req := &http.Request{RemoteAddr: test.remoteAddr, Header: http.Header{}}
+		for _, forwarded := range test.forwarded {
+			req.Header.Add("X-Forwarded-For", forwarded)
+		}
+		if got := SourceIP(req, test.trusted); got != test.want {
+			t.Errorf("%s: SourceIP() = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+// fakeAuth lets through basic auth requests with the password "secret" and OIDC tokens signed "valid"
+func fakeAuth(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+	if _, password, ok := req.Request.BasicAuth(); ok && password == "secret" {
+		chain.ProcessFilter(req, res)
+		return
+	}
+	if strings.HasSuffix(req.Request.Header.Get("Authorization"), ".valid") {
+		chain.ProcessFilter(req, res)
+		return
+	}
+	if strings.HasPrefix(req.Request.URL.Path, "/api/v1/health") {
+		chain.ProcessFilter(req, res)
+		return
+	}
+	res.WriteErrorString(http.StatusUnauthorized, "unauthorized")
+}
+
+// testToken returns an unsigned JWT with the claims and signature
+func testToken(claims, signature string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	return "Bearer " + header + "." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + "." + signature
+}
+
+func TestIdentityFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		basicAuth bool
+		oidc      bool
+		setup     func(req *http.Request)
+		status    int
+		want      *Principal
+	}{
+		{
+			name:      "basic auth",
+			path:      "/api/v1/releases",
+			basicAuth: true,
+			setup:     func(req *http.Request) { req.SetBasicAuth("alice", "secret") },
+			status:    http.StatusOK,
+			want:      &Principal{User: "alice"},
+		},
+		{
+			name:      "wrong password",
+			path:      "/api/v1/releases",
+			basicAuth: true,
+			setup:     func(req *http.Request) { req.SetBasicAuth("alice", "wrong") },
+			status:    http.StatusUnauthorized,
+		},
+		{
+			name: "oidc token",
+			path: "/api/v1/releases",
+			oidc: true,
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", testToken(`{"email":"bob@example.com","groups":["ops"]}`, "valid"))
+			},
+			status: http.StatusOK,
+			want:   &Principal{User: "bob@example.com", Groups: []string{"ops"}},
+		},
+		{
+			name:   "oidc token falls back to the subject",
+			path:   "/api/v1/releases",
+			oidc:   true,
+			setup:  func(req *http.Request) { req.Header.Set("Authorization", testToken(`{"sub":"1234"}`, "valid")) },
+			status: http.StatusOK,
+			want:   &Principal{User: "1234"},
+		},
+		{
+			name: "forged oidc token",
+			path: "/api/v1/releases",
+			oidc: true,
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", testToken(`{"email":"admin@example.com"}`, "forged"))
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:      "oidc token without oidc enabled",
+			path:      "/api/v1/releases",
+			basicAuth: true,
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", testToken(`{"email":"bob@example.com"}`, "valid"))
+			},
+			status: http.StatusOK,
+			want:   &Principal{User: anonymous},
+		},
+		{
+			name: "exception",
+			path: "/api/v1/health",
+			oidc: true,
+			setup: func(req *http.Request) {
+				req.Header.Set("Authorization", testToken(`{"email":"admin@example.com"}`, "forged"))
+			},
+			status: http.StatusOK,
+			want:   &Principal{User: anonymous},
+		},
+	}
+	for _, test := range tests {
+		var got *Principal
+		idf := NewIdentityFilter(fakeAuth, []string{"/api/v1/health"}, test.basicAuth, test.oidc)
+		chain := &restful.FilterChain{
+			Target: func(req *restful.Request, res *restful.Response) {
+				got = RequestPrincipal(req)
+				res.WriteHeader(http.StatusOK)
+			},
+		}
+		httpReq := httptest.NewRequest(http.MethodGet, test.path, nil)
+		test.setup(httpReq)
+		recorder := httptest.NewRecorder()
+		idf.Identify(restful.NewRequest(httpReq), restful.NewResponse(recorder), chain)
+
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.status)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: principal = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRequestPrincipalWithoutIdentityFilter(t *testing.T) {
+	httpReq := httptest.NewRequest(http.MethodGet, "/api/v1/releases", nil)
+	httpReq.SetBasicAuth("alice", "secret")
+	if got := RequestPrincipal(restful.NewRequest(httpReq)); got.User != anonymous {
+		t.Errorf("user = %s, want %s", got.User, anonymous)
+	}
+}
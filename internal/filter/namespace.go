@@ -0,0 +1,47 @@
+package filter
+
+import (
+	"github.com/emicklei/go-restful"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultNamespace = "default"
+
+	releaseNamespaceAttribute = "rudder.release-namespace"
+)
+
+// NamespaceResolver finds the namespace of an existing release in a cluster. It returns an empty
+// string if the release doesn't exist.
+type NamespaceResolver func(ctx context.Context, cluster, name string) (string, error)
+
+// resolvedNamespace is the namespace of the request's release, kept as a request attribute
+type resolvedNamespace struct {
+	namespace string
+	err       error
+}
+
+// RequestNamespace returns the namespace the release request acts on: the namespace of the
+// release in the `release` path parameter if it exists, otherwise the requested namespace or
+// "default". exists is false if the release doesn't exist yet. The release is looked up once per
+// request, so the audit and authz filters always agree on the namespace.
+func RequestNamespace(req *restful.Request, resolve NamespaceResolver, requested string) (namespace string, exists bool, err error) {
+	if name := req.PathParameter("release"); name != "" && resolve != nil {
+		resolved, found := req.Attribute(releaseNamespaceAttribute).(*resolvedNamespace)
+		if !found {
+			namespace, err := resolve(req.Request.Context(), req.PathParameter("cluster"), name)
+			resolved = &resolvedNamespace{namespace: namespace, err: err}
+			req.SetAttribute(releaseNamespaceAttribute, resolved)
+		}
+		if resolved.err != nil {
+			return "", false, resolved.err
+		}
+		if resolved.namespace != "" {
+			return resolved.namespace, true, nil
+		}
+	}
+	if requested == "" {
+		requested = defaultNamespace
+	}
+	return requested, false, nil
+}
@@ -0,0 +1,42 @@
+package filter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/emicklei/go-restful"
+)
+
+// RequestIDHeader is the header carrying the request ID
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDFilter provides a go-restful filter tagging every request with an ID
+type RequestIDFilter struct{}
+
+// NewRequestIDFilter returns a filter that tags every request with an ID
+func NewRequestIDFilter() *RequestIDFilter {
+	return &RequestIDFilter{}
+}
+
+// RequestID keeps the X-Request-ID of the request, or generates a new one, and sets it on the
+// request and response
+func (rf *RequestIDFilter) RequestID(req *restful.Request, res *restful.Response, chain *restful.FilterChain) {
+	id := req.HeaderParameter(RequestIDHeader)
+	if id == "" {
+		id = newRequestID()
+		req.Request.Header.Set(RequestIDHeader, id)
+	}
+	res.AddHeader(RequestIDHeader, id)
+	chain.ProcessFilter(req, res)
+}
+
+// newRequestID returns a random hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.WithError(err).Warn("unable to generate request id")
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,93 @@
+package resource
+
+import (
+	"strconv"
+	"time"
+
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+
+	"github.com/AcalephStorage/rudder/internal/controller"
+	"github.com/AcalephStorage/rudder/internal/filter"
+)
+
+var (
+	errInvalidAuditQuery   = restful.NewError(http.StatusBadRequest, "invalid audit query")
+	errFailToQueryAuditLog = restful.NewError(http.StatusInternalServerError, "unable to query audit log")
+)
+
+// AuditResource represents the audit log of mutating API calls
+type AuditResource struct {
+	controller *controller.AuditController
+	authz      *filter.AuthzFilter
+}
+
+// NewAuditResource creates a new AuditResource
+func NewAuditResource(controller *controller.AuditController, authz *filter.AuthzFilter) *AuditResource {
+	return &AuditResource{
+		controller: controller,
+		authz:      authz,
+	}
+}
+
+// Register registers this resource to the provided container
+func (ar *AuditResource) Register(container *restful.Container) {
+
+	ws := new(restful.WebService)
+	ws.Path("/api/v1/audit").
+		Doc("Audit log").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	// GET /api/v1/audit
+	ws.Route(ws.GET("").To(ar.queryAuditLog).
+		Doc("query the audit log, newest first. defaults: limit=100.").
+		Operation("queryAuditLog").
+		Filter(ar.authz.AuthorizeGlobal(controller.VerbAdmin)).
+		Param(ws.QueryParameter("since", "only records at or after this time (RFC3339)")).
+		Param(ws.QueryParameter("until", "only records at or before this time (RFC3339)")).
+		Param(ws.QueryParameter("user", "only records of this user")).
+		Param(ws.QueryParameter("release", "only records of this release")).
+		Param(ws.QueryParameter("limit", "max number of records to return. 0 returns all")).
+		Writes([]controller.AuditRecord{}))
+
+	container.Add(ws)
+}
+
+// queryAuditLog returns the matching audit records
+func (ar *AuditResource) queryAuditLog(req *restful.Request, res *restful.Response) {
+	query := controller.AuditQuery{
+		User:    req.QueryParameter("user"),
+		Release: req.QueryParameter("release"),
+		Limit:   100,
+	}
+	var err error
+	if since := req.QueryParameter("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			errorResponse(err, res, errInvalidAuditQuery)
+			return
+		}
+	}
+	if until := req.QueryParameter("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			errorResponse(err, res, errInvalidAuditQuery)
+			return
+		}
+	}
+	if limit := req.QueryParameter("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 0 {
+			errorResponse(err, res, errInvalidAuditQuery)
+			return
+		}
+	}
+
+	records, err := ar.controller.Query(query)
+	if err != nil {
+		errorResponse(err, res, errFailToQueryAuditLog)
+		return
+	}
+	if err := res.WriteEntity(records); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
@@ -0,0 +1,62 @@
+package resource
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+
+	"github.com/AcalephStorage/rudder/internal/controller"
+	"github.com/AcalephStorage/rudder/internal/filter"
+)
+
+var (
+	errClusterNotFound = restful.NewError(http.StatusNotFound, "cluster not found")
+)
+
+// ClusterResource represents the tiller backends. It also serves the release routes of every
+// cluster, at /api/v1/clusters/{cluster}/releases.
+type ClusterResource struct {
+	controller *controller.ClusterController
+	authz      *filter.AuthzFilter
+	releases   *ReleaseResource
+}
+
+// NewClusterResource creates a new ClusterResource. releases may be nil to only list the clusters.
+func NewClusterResource(controller *controller.ClusterController, authz *filter.AuthzFilter, releases *ReleaseResource) *ClusterResource {
+	return &ClusterResource{
+		controller: controller,
+		authz:      authz,
+		releases:   releases,
+	}
+}
+
+// Register registers this resource to the provided container
+func (cr *ClusterResource) Register(container *restful.Container) {
+
+	ws := new(restful.WebService)
+	ws.Path("/api/v1/clusters").
+		Doc("Clusters").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	// GET /api/v1/clusters
+	ws.Route(ws.GET("").To(cr.listClusters).
+		Doc("list clusters. their releases are at /api/v1/clusters/{cluster}/releases").
+		Operation("listClusters").
+		Filter(cr.authz.AuthorizeGlobal(controller.VerbList)).
+		Writes([]controller.ClusterInfo{}))
+
+	if cr.releases != nil {
+		cr.releases.addClusterRoutes(ws)
+	}
+
+	container.Add(ws)
+}
+
+// listClusters returns the configured clusters
+func (cr *ClusterResource) listClusters(req *restful.Request, res *restful.Response) {
+	clusters := cr.controller.ListClusters()
+	if err := res.WriteEntity(clusters); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
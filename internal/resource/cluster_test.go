@@ -0,0 +1,36 @@
+package resource
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+)
+
+func TestClusterRoutes(t *testing.T) {
+	container := restful.NewContainer()
+	releases := NewReleaseResource(nil, nil, nil, nil, "test")
+	releases.Register(container)
+	NewClusterResource(nil, nil, releases).Register(container)
+	NewHealthResource(nil).Register(container)
+
+	routes := make(map[string]bool)
+	for _, ws := range container.RegisteredWebServices() {
+		for _, route := range ws.Routes() {
+			routes[route.Method+" "+strings.TrimSuffix(route.Path, "/")] = true
+		}
+	}
+	for _, route := range []string{
+		"GET /api/v1/clusters",
+		"GET /api/v1/clusters/{cluster}/releases",
+		"DELETE /api/v1/clusters/{cluster}/releases/{release}",
+		"GET /api/v1/clusters/{cluster}/version",
+		"GET /api/v1/releases",
+		"DELETE /api/v1/releases/{release}",
+		"GET /api/v1/version",
+	} {
+		if !routes[route] {
+			t.Errorf("route %s is not registered", route)
+		}
+	}
+}
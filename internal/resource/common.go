@@ -1,10 +1,16 @@
 package resource
 
 import (
+	"fmt"
+	"strings"
+
+	"encoding/json"
 	"net/http"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/emicklei/go-restful"
+
+	"github.com/AcalephStorage/rudder/internal/filter"
 )
 
 var (
@@ -12,10 +18,94 @@ var (
 	errFailToWriteResponse = restful.NewError(http.StatusInternalServerError, "unable to write response")
 )
 
-// errorResponse creates an error response from the given error
+const mimeEventStream = "text/event-stream"
+
+// errorResponse creates an error response from the given error. Known tiller errors get their own
+// status and code, anything else gets the ones of the service error. Retry-After is set while
+// tiller is known to be unavailable.
 func errorResponse(origErr error, res *restful.Response, err restful.ServiceError) {
-	log.WithError(origErr).Error(err.Message)
-	if err := res.WriteServiceError(err.Code, err); err != nil {
-		log.WithError(origErr).Error("unable to write error")
+	requestID := res.Header().Get(filter.RequestIDHeader)
+	log.WithError(origErr).WithField("request_id", requestID).Error(err.Message)
+	httpStatus, code, ok := classifyError(origErr)
+	if !ok {
+		httpStatus, code = err.Code, errorCode(err.Code)
+	}
+	wait, _ := retryAfter(origErr)
+	// errors are JSON, also for clients only accepting event streams
+	res.SetRequestAccepts(restful.MIME_JSON)
+	body := filter.ErrorBody{
+		Code:    code,
+		Message: err.Message,
+		Error:   upstreamMessage(origErr),
+	}
+	filter.WriteError(res, httpStatus, body, wait)
+}
+
+// streamWriter writes a stream of JSON messages to the response, flushing after every message.
+// Messages are written as server-sent events if the client accepts them, or as JSON lines otherwise.
+type streamWriter struct {
+	res     *restful.Response
+	sse     bool
+	started bool
+}
+
+// newStreamWriter creates a streamWriter for the given request and response
+func newStreamWriter(req *restful.Request, res *restful.Response) *streamWriter {
+	sse := strings.Contains(req.HeaderParameter("Accept"), mimeEventStream)
+	return &streamWriter{res: res, sse: sse}
+}
+
+// start writes the response headers. It is called by the first write if not called before.
+func (sw *streamWriter) start() {
+	if sw.started {
+		return
+	}
+	if sw.sse {
+		sw.res.Header().Set("Content-Type", mimeEventStream)
+		sw.res.Header().Set("Cache-Control", "no-cache")
+	} else {
+		sw.res.Header().Set("Content-Type", restful.MIME_JSON)
+	}
+	sw.res.WriteHeader(http.StatusOK)
+	sw.started = true
+	sw.flush()
+}
+
+// write sends a single message. event is only used for server-sent events
+func (sw *streamWriter) write(event string, msg interface{}) error {
+	sw.start()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if sw.sse {
+		_, err = fmt.Fprintf(sw.res, "event: %s\ndata: %s\n\n", event, data)
+	} else {
+		_, err = fmt.Fprintf(sw.res, "%s\n", data)
+	}
+	if err != nil {
+		return err
+	}
+	sw.flush()
+	return nil
+}
+
+// heartbeat sends a server-sent events comment to keep idle connections open
+func (sw *streamWriter) heartbeat() error {
+	sw.start()
+	if !sw.sse {
+		return nil
+	}
+	if _, err := fmt.Fprint(sw.res, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	sw.flush()
+	return nil
+}
+
+// flush sends buffered data to the client
+func (sw *streamWriter) flush() {
+	if flusher, ok := sw.res.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
 	}
 }
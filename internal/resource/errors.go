@@ -0,0 +1,111 @@
+package resource
+
+import (
+	"time"
+
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/AcalephStorage/rudder/internal/client"
+	"github.com/AcalephStorage/rudder/internal/controller"
+	"github.com/AcalephStorage/rudder/internal/util"
+)
+
+// machine-readable error codes
+const (
+	codeBadRequest         = "bad_request"
+	codeForbidden          = "forbidden"
+	codeNotFound           = "not_found"
+	codeAlreadyExists      = "already_exists"
+	codeInvalidArgument    = "invalid_argument"
+	codeInternalError      = "internal_error"
+	codeTillerUnavailable  = "tiller_unavailable"
+	codeTillerTimeout      = "tiller_timeout"
+	codeServiceUnavailable = "service_unavailable"
+)
+
+// grpcErrors maps the gRPC status codes to the HTTP status and error code
+var grpcErrors = map[codes.Code]struct {
+	status int
+	code   string
+}{
+	codes.NotFound:         {http.StatusNotFound, codeNotFound},
+	codes.AlreadyExists:    {http.StatusConflict, codeAlreadyExists},
+	codes.InvalidArgument:  {http.StatusUnprocessableEntity, codeInvalidArgument},
+	codes.Unavailable:      {http.StatusServiceUnavailable, codeTillerUnavailable},
+	codes.DeadlineExceeded: {http.StatusGatewayTimeout, codeTillerTimeout},
+}
+
+// knownErrors maps the rudder and tiller errors to the HTTP status and error code
+var knownErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{client.ErrReleaseNotFound, http.StatusNotFound, codeNotFound},
+	{client.ErrReleaseExists, http.StatusConflict, codeAlreadyExists},
+	{controller.ErrRepoNotFound, http.StatusNotFound, codeNotFound},
+	{controller.ErrChartNotFound, http.StatusNotFound, codeNotFound},
+	{controller.ErrClusterNotFound, http.StatusNotFound, codeNotFound},
+}
+
+// statusCodes maps the HTTP status of the fallback service errors to the error code
+var statusCodes = map[int]string{
+	http.StatusBadRequest:         codeBadRequest,
+	http.StatusForbidden:          codeForbidden,
+	http.StatusNotFound:           codeNotFound,
+	http.StatusServiceUnavailable: codeServiceUnavailable,
+}
+
+// classifyError returns the HTTP status and error code of a known error or gRPC status. ok is
+// false if the error is unknown, and the fallback service error should be used.
+func classifyError(err error) (httpStatus int, code string, ok bool) {
+	if err == nil {
+		return 0, "", false
+	}
+	if _, open := util.RootCause(err).(*client.CircuitOpenError); open {
+		return http.StatusServiceUnavailable, codeTillerUnavailable, true
+	}
+	for _, known := range knownErrors {
+		if util.IsError(err, known.err) {
+			return known.status, known.code, true
+		}
+	}
+	if st, isGRPC := status.FromError(util.RootCause(err)); isGRPC {
+		if mapped, found := grpcErrors[st.Code()]; found {
+			return mapped.status, mapped.code, true
+		}
+	}
+	return 0, "", false
+}
+
+// errorCode returns the error code of an HTTP status
+func errorCode(httpStatus int) string {
+	if code, found := statusCodes[httpStatus]; found {
+		return code
+	}
+	return codeInternalError
+}
+
+// upstreamMessage returns the message of the root cause of the error, without the gRPC prefix
+func upstreamMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	cause := util.RootCause(err)
+	if st, ok := status.FromError(cause); ok {
+		return st.Message()
+	}
+	return cause.Error()
+}
+
+// retryAfter returns how long the client should wait before retrying, if tiller is known to be
+// unavailable for a while
+func retryAfter(err error) (time.Duration, bool) {
+	if coe, open := util.RootCause(err).(*client.CircuitOpenError); open {
+		return coe.RetryAfter, true
+	}
+	return 0, false
+}
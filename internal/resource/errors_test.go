@@ -0,0 +1,57 @@
+package resource
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emicklei/go-restful"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/AcalephStorage/rudder/internal/client"
+	"github.com/AcalephStorage/rudder/internal/filter"
+)
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		fallback restful.ServiceError
+		status   int
+		code     string
+		message  string
+	}{
+		{"unknown tiller error", status.Error(codes.Unknown, "boom"), errFailToListReleases, http.StatusInternalServerError, codeInternalError, "boom"},
+		{"tiller unavailable", status.Error(codes.Unavailable, "down"), errFailToListReleases, http.StatusServiceUnavailable, codeTillerUnavailable, "down"},
+		{"tiller timeout", status.Error(codes.DeadlineExceeded, "slow"), errFailToListReleases, http.StatusGatewayTimeout, codeTillerTimeout, "slow"},
+		{"circuit open", &client.CircuitOpenError{Address: "tiller", RetryAfter: time.Second}, errFailToListReleases, http.StatusServiceUnavailable, codeTillerUnavailable, ""},
+		{"release not found", client.ErrReleaseNotFound, errFailToListReleases, http.StatusNotFound, codeNotFound, ""},
+		{"invalid request", errors.New("bad"), errInvalidValues, http.StatusBadRequest, codeBadRequest, "bad"},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		res := restful.NewResponse(recorder)
+		res.SetRequestAccepts(restful.MIME_JSON)
+		errorResponse(test.err, res, test.fallback)
+
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.status)
+		}
+		var body filter.ErrorBody
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: unable to parse body: %v", test.name, err)
+			continue
+		}
+		if body.Code != test.code || body.Message != test.fallback.Message {
+			t.Errorf("%s: body = %+v, want code %s and message %q", test.name, body, test.code, test.fallback.Message)
+		}
+		if test.message != "" && body.Error != test.message {
+			t.Errorf("%s: error = %q, want %q", test.name, body.Error, test.message)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package resource
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+
+	"github.com/AcalephStorage/rudder/internal/controller"
+)
+
+// health statuses
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+// HealthResponse is the readiness of rudder. The connection state of every cluster is only
+// reported by the authenticated clusters resource.
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// HealthResource represents the readiness of rudder
+type HealthResource struct {
+	clusters *controller.ClusterController
+}
+
+// NewHealthResource creates a new HealthResource
+func NewHealthResource(clusters *controller.ClusterController) *HealthResource {
+	return &HealthResource{clusters: clusters}
+}
+
+// Register registers this resource to the provided container
+func (hr *HealthResource) Register(container *restful.Container) {
+
+	ws := new(restful.WebService)
+	ws.Path("/api/v1/health").
+		Doc("Readiness").
+		Produces(restful.MIME_JSON)
+
+	// GET /api/v1/health
+	ws.Route(ws.GET("").To(hr.health).
+		Doc("get the readiness of rudder. responds with 503 if the default cluster's tiller is unreachable.").
+		Operation("health").
+		Writes(HealthResponse{}))
+
+	container.Add(ws)
+}
+
+// health returns the readiness, with 503 if rudder is not ready
+func (hr *HealthResource) health(req *restful.Request, res *restful.Response) {
+	out := HealthResponse{Status: healthOK}
+	status := http.StatusOK
+	if !hr.clusters.Ready() {
+		out.Status = healthUnavailable
+		status = http.StatusServiceUnavailable
+	}
+	if err := res.WriteHeaderAndEntity(status, out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
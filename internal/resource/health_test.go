@@ -0,0 +1,51 @@
+package resource
+
+import (
+	"testing"
+	"time"
+
+	"encoding/json"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+)
+
+func TestHealth(t *testing.T) {
+	servers, clusters, stop := newTestClusters(t)
+	defer stop()
+	container := restful.NewContainer()
+	NewHealthResource(clusters).Register(container)
+
+	// waitForStatus polls the health until it responds with the status
+	waitForStatus := func(want int) map[string]interface{} {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			recorder := send(container, http.MethodGet, "/api/v1/health", "")
+			if recorder.Code == want || time.Now().After(deadline) {
+				if recorder.Code != want {
+					t.Fatalf("status = %d, want %d", recorder.Code, want)
+				}
+				var body map[string]interface{}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+					t.Fatalf("unable to parse body: %v", err)
+				}
+				return body
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	body := waitForStatus(http.StatusOK)
+	if len(body) != 1 || body["status"] != healthOK {
+		t.Errorf("body = %v, want only the ok status", body)
+	}
+
+	// only the tiller of the default cluster matters
+	servers["staging"].Stop()
+	waitForStatus(http.StatusOK)
+	servers["default"].Stop()
+	body = waitForStatus(http.StatusServiceUnavailable)
+	if len(body) != 1 || body["status"] != healthUnavailable {
+		t.Errorf("body = %v, want only the unavailable status", body)
+	}
+}
@@ -0,0 +1,83 @@
+package resource
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+
+	"github.com/AcalephStorage/rudder/internal/controller"
+	"github.com/AcalephStorage/rudder/internal/filter"
+)
+
+var (
+	errFailToGetOperation    = restful.NewError(http.StatusNotFound, "operation not found")
+	errFailToSubmitOperation = restful.NewError(http.StatusServiceUnavailable, "unable to queue operation")
+)
+
+// OperationResource represents asynchronous release operations
+type OperationResource struct {
+	controller *controller.OperationController
+	authz      *filter.AuthzFilter
+}
+
+// NewOperationResource creates a new OperationResource
+func NewOperationResource(controller *controller.OperationController, authz *filter.AuthzFilter) *OperationResource {
+	return &OperationResource{
+		controller: controller,
+		authz:      authz,
+	}
+}
+
+// Register registers this resource to the provided container
+func (or *OperationResource) Register(container *restful.Container) {
+
+	ws := new(restful.WebService)
+	ws.Path("/api/v1/operations").
+		Doc("Asynchronous release operations").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	// GET /api/v1/operations/{id}
+	ws.Route(ws.GET("/{id}").To(or.getOperation).
+		Doc("get the status, result and error of an operation").
+		Operation("getOperation").
+		Filter(or.authz.AuthorizeOperation(or.controller)).
+		Param(ws.PathParameter("id", "the operation id")).
+		Writes(controller.Operation{}))
+
+	container.Add(ws)
+}
+
+// getOperation returns the operation details
+func (or *OperationResource) getOperation(req *restful.Request, res *restful.Response) {
+	id := req.PathParameter("id")
+	op, err := or.controller.Get(id)
+	if err != nil {
+		errorResponse(err, res, errFailToGetOperation)
+		return
+	}
+	if err := res.WriteEntity(op); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
+
+// submitOperation runs the operation in the background and responds with 202 Accepted. The
+// operation keeps the cluster and namespace of the release, to authorize reading it, and its id is
+// set on the request for the audit filter.
+func submitOperation(operations *controller.OperationController, releaseNamespace filter.NamespaceResolver, req *restful.Request, res *restful.Response, opType, release, namespace string, run func() (interface{}, error)) {
+	namespace, _, err := filter.RequestNamespace(req, releaseNamespace, namespace)
+	if err != nil {
+		errorResponse(err, res, errFailToSubmitOperation)
+		return
+	}
+	op, err := operations.Submit(opType, req.PathParameter("cluster"), namespace, release, run)
+	if err != nil {
+		errorResponse(err, res, errFailToSubmitOperation)
+		return
+	}
+	req.SetAttribute(filter.OperationIDAttribute, op.ID)
+	res.AddHeader("Location", "/api/v1/operations/"+op.ID)
+	if err := res.WriteHeaderAndEntity(http.StatusAccepted, op); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
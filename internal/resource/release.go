@@ -1,17 +1,21 @@
 package resource
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"net/http"
 
 	log "github.com/Sirupsen/logrus"
 	restful "github.com/emicklei/go-restful"
+	"golang.org/x/net/context"
 	"k8s.io/helm/pkg/proto/hapi/release"
 	tiller "k8s.io/helm/pkg/proto/hapi/services"
 
 	"github.com/AcalephStorage/rudder/internal/controller"
+	"github.com/AcalephStorage/rudder/internal/filter"
 	"github.com/AcalephStorage/rudder/internal/util"
 )
 
@@ -35,97 +39,327 @@ var (
 )
 
 var (
-	errFailToListReleases      = restful.NewError(http.StatusBadRequest, "unable to get list of releases")
+	errFailToListReleases      = restful.NewError(http.StatusInternalServerError, "unable to get list of releases")
 	errFailToInstallRelease    = restful.NewError(http.StatusInternalServerError, "unable to install releases")
 	errFailToUpdateRelease     = restful.NewError(http.StatusInternalServerError, "unable to update releases")
 	errFailtToUninstallRelease = restful.NewError(http.StatusInternalServerError, "unable to uninstall releases")
 	errFailToGetRelease        = restful.NewError(http.StatusInternalServerError, "unable to get release content and status")
+	errFailToRollbackRelease   = restful.NewError(http.StatusInternalServerError, "unable to rollback release")
+	errFailToGetReleaseHistory = restful.NewError(http.StatusInternalServerError, "unable to get release history")
+	errFailToTestRelease       = restful.NewError(http.StatusInternalServerError, "unable to run release tests")
+	errFailToGetVersion        = restful.NewError(http.StatusInternalServerError, "unable to get tiller version")
+	errFailToDiffRelease       = restful.NewError(http.StatusInternalServerError, "unable to diff release")
+	errInvalidValues           = restful.NewError(http.StatusBadRequest, "unable to parse values")
+	errInvalidUpdateOptions    = restful.NewError(http.StatusBadRequest, "invalid update options")
+	errFailToGetReleaseObjects = restful.NewError(http.StatusInternalServerError, "unable to get release resources")
+	errFailToGetReleaseStatus  = restful.NewError(http.StatusInternalServerError, "unable to get release status")
+	errFailToGetReleaseContent = restful.NewError(http.StatusInternalServerError, "unable to get release content")
+	errFailToGetReleaseNotes   = restful.NewError(http.StatusInternalServerError, "unable to get release notes")
+	errFailToGetReleaseHooks   = restful.NewError(http.StatusInternalServerError, "unable to get release hooks")
+	errFailToWatchReleases     = restful.NewError(http.StatusInternalServerError, "unable to watch releases")
+	errInvalidTestTimeout      = restful.NewError(http.StatusBadRequest, "invalid test timeout")
+	errInvalidHistoryMax       = restful.NewError(http.StatusBadRequest, "invalid max number of revisions")
+	errInvalidRevision         = restful.NewError(http.StatusBadRequest, "invalid revision")
+	errInvalidWatchStatus      = restful.NewError(http.StatusBadRequest, "invalid status code to watch")
 )
 
+// ReleaseValues are the value overrides of an install or update. They are merged in the order:
+// values_yaml documents, values, then set. Later ones take precedence.
+type ReleaseValues struct {
+	ValuesYAML []string               `json:"values_yaml"`
+	Values     map[string]interface{} `json:"values"`
+	Set        []string               `json:"set"`
+}
+
+// merge returns the merged values
+func (rv *ReleaseValues) merge() (map[string]interface{}, error) {
+	return controller.MergeValues(rv.ValuesYAML, rv.Values, rv.Set)
+}
+
 // InstallReleaseRequest is the request body needed for installing a new release
 type InstallReleaseRequest struct {
-	Name      string                 `json:"name"`
-	Namespace string                 `json:"namespace"`
-	Repo      string                 `json:"repo"`
-	Chart     string                 `json:"chart"`
-	Version   string                 `json:"version"`
-	Values    map[string]interface{} `json:"values"`
+	ReleaseValues
+	controller.InstallOptions
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Repo      string `json:"repo"`
+	Chart     string `json:"chart"`
+	Version   string `json:"version"`
 }
 
-// UpdateReleaseRequest is the request body needed for updating a release
+// UpdateReleaseRequest is the request body needed for updating a release. Namespace is only
+// used if the release gets installed.
 type UpdateReleaseRequest struct {
-	Repo    string                 `json:"repo"`
-	Chart   string                 `json:"chart"`
-	Version string                 `json:"version"`
-	Values  map[string]interface{} `json:"values"`
+	ReleaseValues
+	controller.UpdateOptions
+	Namespace string `json:"namespace"`
+	Repo      string `json:"repo"`
+	Chart     string `json:"chart"`
+	Version   string `json:"version"`
+}
+
+// newUpdateReleaseRequest returns an UpdateReleaseRequest with the defaults set
+func newUpdateReleaseRequest() UpdateReleaseRequest {
+	return UpdateReleaseRequest{
+		UpdateOptions: controller.UpdateOptions{Timeout: 300},
+		Version:       "latest",
+	}
 }
 
-// ReleaseResource represents helm releases
+// RollbackReleaseRequest is the request body needed for rolling back a release
+type RollbackReleaseRequest struct {
+	Version      int32 `json:"version"`
+	Recreate     bool  `json:"recreate"`
+	Force        bool  `json:"force"`
+	Wait         bool  `json:"wait"`
+	Timeout      int64 `json:"timeout"`
+	DisableHooks bool  `json:"disable_hooks"`
+}
+
+// ReleaseResource represents helm releases. Every route is available for the default cluster at
+// /api/v1/releases and for any cluster at /api/v1/clusters/{cluster}/releases.
 type ReleaseResource struct {
-	controller *controller.ReleaseController
+	clusters   *controller.ClusterController
+	operations *controller.OperationController
+	audit      *filter.AuditFilter
+	authz      *filter.AuthzFilter
+	version    string
 }
 
-// NewReleaseResource creates a new ReleaseResource instance
-func NewReleaseResource(controller *controller.ReleaseController) *ReleaseResource {
-	return &ReleaseResource{controller: controller}
+// clusterHandler is a route function using the release controller of the request's cluster
+type clusterHandler func(rc *controller.ReleaseController, req *restful.Request, res *restful.Response)
+
+// NewReleaseResource creates a new ReleaseResource instance. version is the rudder build version
+func NewReleaseResource(clusters *controller.ClusterController, operations *controller.OperationController, audit *filter.AuditFilter, authz *filter.AuthzFilter, version string) *ReleaseResource {
+	return &ReleaseResource{
+		clusters:   clusters,
+		operations: operations,
+		audit:      audit,
+		authz:      authz,
+		version:    version,
+	}
 }
 
-// Register registers this to the provided container
+// Register registers this to the provided container. The routes of every cluster are served by
+// the cluster resource.
 func (rr *ReleaseResource) Register(container *restful.Container) {
 
 	ws := new(restful.WebService)
 	ws.Path("/api/v1/releases").
-		Doc("Helm releases").
+		Doc("Helm releases of the default cluster").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
+	rr.addRoutes(ws, "")
+	container.Add(ws)
+
+	vws := new(restful.WebService)
+	vws.Path("/api/v1/version").
+		Doc("Rudder and Tiller versions").
+		Produces(restful.MIME_JSON)
+
+	// GET /api/v1/version
+	vws.Route(vws.GET("").To(rr.inCluster(rr.getVersion)).
+		Doc("get rudder, helm and tiller versions of the default cluster").
+		Operation("getVersion").
+		Writes(controller.VersionResponse{}))
+
+	container.Add(vws)
+}
+
+// addClusterRoutes adds the release and version routes of every cluster to the web service at
+// /api/v1/clusters. go-restful maps every web service to its path prefix, so the web services
+// under /api/v1/clusters can't be registered separately.
+func (rr *ReleaseResource) addClusterRoutes(ws *restful.WebService) {
+	rr.addRoutes(ws, "/{cluster}/releases")
+
+	// GET /api/v1/clusters/{cluster}/version
+	ws.Route(ws.GET("/{cluster}/version").To(rr.inCluster(rr.getVersion)).
+		Doc("get rudder, helm and tiller versions of the cluster").
+		Operation("getClusterVersion").
+		Param(ws.PathParameter("cluster", "the cluster name")).
+		Writes(controller.VersionResponse{}))
+}
+
+// addRoutes adds the release routes to the web service, at root below the path of the web service
+func (rr *ReleaseResource) addRoutes(ws *restful.WebService, root string) {
 
 	// GET /api/v1/releases
-	ws.Route(ws.GET("").To(rr.listReleases).
+	ws.Route(ws.GET(root).To(rr.inCluster(rr.listReleases)).
 		Doc("list releases").
 		Operation("listReleases").
+		Filter(rr.authz.Authorize(controller.VerbList)).
 		Param(ws.QueryParameter("limit", "max number of releases to return")).
-		Param(ws.QueryParameter("offset", "last release name that was seen")).
+		Param(ws.QueryParameter("offset", "release name to start from. use 'next' of the previous page")).
 		Param(ws.QueryParameter("sort-by", "sort by: unknown, name, last-released")).
 		Param(ws.QueryParameter("filter", "regex to filter releases")).
 		Param(ws.QueryParameter("sort-order", "sort order: asc, desc")).
 		Param(ws.QueryParameter("status-code", "comma-separated status codes: unknown, deployed, deleted, superseded, failed")).
-		Writes(tiller.ListReleasesResponse{}))
+		Param(ws.QueryParameter("namespace", "only list releases in this namespace")).
+		Writes(controller.ListReleasesResponse{}))
+
+	// GET /api/v1/releases/watch
+	ws.Route(ws.GET(root + "/watch").To(rr.watchReleases).
+		Doc("watch releases. created, upgraded, deleted and status-changed events are streamed as server-sent events.").
+		Operation("watchReleases").
+		Filter(rr.authz.Authorize(controller.VerbList)).
+		Produces(mimeEventStream).
+		Param(ws.QueryParameter("namespace", "only watch releases in this namespace")).
+		Param(ws.QueryParameter("status-code", "comma-separated status codes: unknown, deployed, deleted, failed")).
+		Writes(controller.ReleaseEvent{}))
 
 	// POST /api/v1/releases
-	ws.Route(ws.POST("").To(rr.installRelease).
-		Doc("install release. defaults: namespace=default, version=latest.").
+	ws.Route(ws.POST(root).To(rr.inCluster(rr.installRelease)).
+		Doc("install release. defaults: namespace=default, version=latest, timeout=300. with dry_run set, the rendered manifest, hooks and notes are returned instead.").
 		Operation("installRelease").
+		Filter(rr.audit.Audit("installRelease")).
+		Filter(rr.authz.Authorize(controller.VerbInstall)).
+		Param(ws.QueryParameter("async", "run in the background. responds with 202 and the operation to poll")).
 		Reads(InstallReleaseRequest{}).
 		Writes(tiller.InstallReleaseResponse{}))
 
-	// PUT /api/v1/releases
-	ws.Route(ws.PUT("/{release}").To(rr.updateRelease).
-		Doc("update release. defaults: namespace=default, version=latest.").
+	// PUT /api/v1/releases/{release}
+	ws.Route(ws.PUT(root + "/{release}").To(rr.inCluster(rr.updateRelease)).
+		Doc("update release. defaults: namespace=default, version=latest, timeout=300. reuse_values and reset_values can't be used together. with dry_run set, the rendered manifest, hooks and notes are returned instead.").
 		Operation("updateRelease").
+		Filter(rr.audit.Audit("updateRelease")).
+		Filter(rr.authz.Authorize(controller.VerbUpgrade)).
+		Param(ws.PathParameter("release", "the release name to be updated")).
+		Param(ws.QueryParameter("install", "install the release if it doesn't exist, or if it was deleted or never deployed successfully")).
+		Param(ws.QueryParameter("async", "run in the background. responds with 202 and the operation to poll")).
 		Reads(UpdateReleaseRequest{}).
 		Writes(tiller.UpdateReleaseResponse{}))
 
 	// DELETE /api/v1/releases/{release}
-	ws.Route(ws.DELETE("/{release}").To(rr.uninstallRelease).
+	ws.Route(ws.DELETE(root + "/{release}").To(rr.inCluster(rr.uninstallRelease)).
 		Doc("uninstall release").
 		Operation("uninstallRelease").
+		Filter(rr.audit.Audit("uninstallRelease")).
+		Filter(rr.authz.Authorize(controller.VerbDelete)).
 		Param(ws.PathParameter("release", "the release name to be deleted")).
-		Param(ws.QueryParameter("purge", "purge the release")))
+		Param(ws.QueryParameter("purge", "purge the release")).
+		Param(ws.QueryParameter("async", "run in the background. responds with 202 and the operation to poll")))
+
+	// POST /api/v1/releases/{release}/rollback
+	ws.Route(ws.POST(root + "/{release}/rollback").To(rr.inCluster(rr.rollbackRelease)).
+		Doc("rollback release. defaults: version=0 (previous revision), timeout=300.").
+		Operation("rollbackRelease").
+		Filter(rr.audit.Audit("rollbackRelease")).
+		Filter(rr.authz.Authorize(controller.VerbRollback)).
+		Param(ws.PathParameter("release", "the release name to be rolled back")).
+		Reads(RollbackReleaseRequest{}).
+		Writes(tiller.RollbackReleaseResponse{}))
+
+	// POST /api/v1/releases/{release}/test
+	ws.Route(ws.POST(root+"/{release}/test").To(rr.inCluster(rr.testRelease)).
+		Doc("run release tests. results are streamed as JSON lines, or as server-sent events if 'Accept: text/event-stream' is set. defaults: timeout=300.").
+		Operation("testRelease").
+		Filter(rr.audit.Audit("testRelease")).
+		Filter(rr.authz.Authorize(controller.VerbUpgrade)).
+		Produces(restful.MIME_JSON, mimeEventStream).
+		Param(ws.PathParameter("release", "the release name to be tested")).
+		Param(ws.QueryParameter("timeout", "time in seconds to wait for any individual kubernetes operation")).
+		Param(ws.QueryParameter("cleanup", "delete test pods upon completion")).
+		Writes(controller.ReleaseTestMessage{}))
+
+	// GET /api/v1/releases/{release}/diff
+	ws.Route(ws.GET(root + "/{release}/diff").To(rr.inCluster(rr.diffRevisions)).
+		Doc("diff the manifests of two release revisions. defaults: to=deployed revision, from=revision before to.").
+		Operation("diffRevisions").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
+		Param(ws.PathParameter("release", "the release name")).
+		Param(ws.QueryParameter("from", "the revision to compare from")).
+		Param(ws.QueryParameter("to", "the revision to compare to")).
+		Writes(controller.ReleaseDiffResponse{}))
+
+	// POST /api/v1/releases/{release}/diff
+	ws.Route(ws.POST(root + "/{release}/diff").To(rr.inCluster(rr.diffUpdate)).
+		Doc("diff the deployed revision with a dry-run update of the release. defaults: version=latest, timeout=300.").
+		Operation("diffUpdate").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
+		Param(ws.PathParameter("release", "the release name")).
+		Reads(UpdateReleaseRequest{}).
+		Writes(controller.ReleaseDiffResponse{}))
+
+	// GET /api/v1/releases/{release}/history
+	ws.Route(ws.GET(root + "/{release}/history").To(rr.inCluster(rr.releaseHistory)).
+		Doc("get release history. defaults: max=256.").
+		Operation("releaseHistory").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
+		Param(ws.PathParameter("release", "the release name")).
+		Param(ws.QueryParameter("max", "max number of revisions to return")).
+		Writes([]controller.ReleaseRevision{}))
 
 	// GET /api/v1/releases/{release}/{version}
-	ws.Route(ws.GET("/{release}/{version}").To(rr.getRelease).
+	ws.Route(ws.GET(root + "/{release}/{version}").To(rr.inCluster(rr.getRelease)).
 		Doc("get release").
 		Operation("getRelease").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
 		Param(ws.PathParameter("release", "the release name")).
 		Param(ws.PathParameter("version", "the release version")).
 		Writes(controller.GetReleaseResponse{}))
 
-	container.Add(ws)
+	// GET /api/v1/releases/{release}/{version}/status
+	ws.Route(ws.GET(root + "/{release}/{version}/status").To(rr.inCluster(rr.releaseStatus)).
+		Doc("get release status. version 0 is the latest revision.").
+		Operation("releaseStatus").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
+		Param(ws.PathParameter("release", "the release name")).
+		Param(ws.PathParameter("version", "the release version")).
+		Writes(controller.ReleaseStatus{}))
 
+	// GET /api/v1/releases/{release}/{version}/content
+	ws.Route(ws.GET(root + "/{release}/{version}/content").To(rr.inCluster(rr.releaseContent)).
+		Doc("get release content. version 0 is the deployed revision.").
+		Operation("releaseContent").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
+		Param(ws.PathParameter("release", "the release name")).
+		Param(ws.PathParameter("version", "the release version")).
+		Writes(tiller.GetReleaseContentResponse{}))
+
+	// GET /api/v1/releases/{release}/{version}/notes
+	ws.Route(ws.GET(root + "/{release}/{version}/notes").To(rr.inCluster(rr.releaseNotes)).
+		Doc("get the rendered release notes. version 0 is the latest revision.").
+		Operation("releaseNotes").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
+		Param(ws.PathParameter("release", "the release name")).
+		Param(ws.PathParameter("version", "the release version")).
+		Writes(controller.ReleaseNotes{}))
+
+	// GET /api/v1/releases/{release}/{version}/hooks
+	ws.Route(ws.GET(root + "/{release}/{version}/hooks").To(rr.inCluster(rr.releaseHooks)).
+		Doc("list release hooks. version 0 is the deployed revision.").
+		Operation("releaseHooks").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
+		Param(ws.PathParameter("release", "the release name")).
+		Param(ws.PathParameter("version", "the release version")).
+		Writes([]controller.ReleaseHook{}))
+
+	// GET /api/v1/releases/{release}/{version}/resources
+	ws.Route(ws.GET(root + "/{release}/{version}/resources").To(rr.inCluster(rr.releaseObjects)).
+		Doc("list the kubernetes resources of a release. version 0 is the deployed revision.").
+		Operation("releaseObjects").
+		Filter(rr.authz.Authorize(controller.VerbGet)).
+		Param(ws.PathParameter("release", "the release name")).
+		Param(ws.PathParameter("version", "the release version")).
+		Param(ws.QueryParameter("group-by", "group the resources. only 'kind' is supported")).
+		Writes([]controller.KubeObject{}))
+}
+
+// inCluster resolves the release controller of the `cluster` path parameter, or of the default
+// cluster for routes without one
+func (rr *ReleaseResource) inCluster(handler clusterHandler) restful.RouteFunction {
+	return func(req *restful.Request, res *restful.Response) {
+		rc, err := rr.clusters.ReleaseController(req.PathParameter("cluster"))
+		if err != nil {
+			errorResponse(err, res, errClusterNotFound)
+			return
+		}
+		handler(rc, req, res)
+	}
 }
 
 // listReleases returns a list of installed releases
-func (rr *ReleaseResource) listReleases(req *restful.Request, res *restful.Response) {
+func (rr *ReleaseResource) listReleases(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
 	log.Info("Getting list of releases...")
 
 	limit, _ := strconv.ParseInt(req.QueryParameter("limit"), 10, 64)
@@ -133,6 +367,7 @@ func (rr *ReleaseResource) listReleases(req *restful.Request, res *restful.Respo
 	sortBy := sortByMap[req.QueryParameter("sort-by")]
 	filter := req.QueryParameter("filter")
 	sortOrder := sortOrderMap[req.QueryParameter("sort-order")]
+	namespace := req.QueryParameter("namespace")
 	statusCodesRaw := req.QueryParameter("status-code")
 	var statusCodes []release.Status_Code
 	if len(statusCodesRaw) > 0 {
@@ -155,9 +390,10 @@ func (rr *ReleaseResource) listReleases(req *restful.Request, res *restful.Respo
 		Filter:      filter,
 		SortOrder:   sortOrder,
 		StatusCodes: statusCodes,
+		Namespace:   namespace,
 	}
 
-	response, err := rr.controller.ListReleases(request)
+	response, err := rc.ListReleases(req.Request.Context(), request)
 	if err != nil {
 		errorResponse(err, res, errFailToListReleases)
 		return
@@ -167,17 +403,90 @@ func (rr *ReleaseResource) listReleases(req *restful.Request, res *restful.Respo
 	}
 }
 
+// watchReleases streams release events until the client disconnects
+func (rr *ReleaseResource) watchReleases(req *restful.Request, res *restful.Response) {
+	namespace := req.QueryParameter("namespace")
+	statuses := make(map[string]bool)
+	if statusCodesRaw := req.QueryParameter("status-code"); statusCodesRaw != "" {
+		for _, s := range strings.Split(statusCodesRaw, ",") {
+			sc, ok := statusCodeMap[s]
+			// superseded revisions are never watched
+			if !ok || sc == release.Status_SUPERSEDED {
+				errorResponse(fmt.Errorf("unknown status code %s", s), res, errInvalidWatchStatus)
+				return
+			}
+			statuses[sc.String()] = true
+		}
+	}
+
+	watcher, err := rr.clusters.Watcher(req.PathParameter("cluster"))
+	if err != nil {
+		errorResponse(err, res, errClusterNotFound)
+		return
+	}
+	events, unsubscribe := watcher.Subscribe()
+	defer unsubscribe()
+	stream := &streamWriter{res: res, sse: true}
+	stream.start()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-req.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := stream.heartbeat(); err != nil {
+				log.WithError(err).Debug("release watcher disconnected")
+				return
+			}
+		case event := <-events:
+			if namespace != "" && event.Namespace != namespace {
+				continue
+			}
+			if len(statuses) > 0 && !statuses[event.Status] {
+				continue
+			}
+			if err := stream.write(event.Type, event); err != nil {
+				log.WithError(err).Error(errFailToWatchReleases.Message)
+				return
+			}
+		}
+	}
+}
+
 // installRelease installs the provided release and version to the given namespace
-func (rr *ReleaseResource) installRelease(req *restful.Request, res *restful.Response) {
+func (rr *ReleaseResource) installRelease(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
 	in := InstallReleaseRequest{
-		Namespace: "default",
-		Version:   "latest",
+		InstallOptions: controller.InstallOptions{Timeout: 300},
+		Namespace:      "default",
+		Version:        "latest",
 	}
 	if err := req.ReadEntity(&in); err != nil {
 		errorResponse(err, res, errFailToReadResponse)
 		return
 	}
-	out, err := rr.controller.InstallRelease(in.Name, in.Namespace, in.Repo, in.Chart, in.Version, in.Values)
+	values, err := in.merge()
+	if err != nil {
+		errorResponse(err, res, errInvalidValues)
+		return
+	}
+	ctx := requestContext(req)
+	install := func() (interface{}, error) {
+		out, err := rc.InstallRelease(ctx, in.Name, in.Namespace, in.Repo, in.Chart, in.Version, values, in.InstallOptions)
+		if err != nil {
+			return nil, err
+		}
+		if in.DryRun {
+			return controller.NewDryRunResponse(out.Release), nil
+		}
+		return out, nil
+	}
+	if isAsync(req) {
+		submitOperation(rr.operations, rr.clusters.ReleaseNamespace, req, res, "install", in.Name, in.Namespace, install)
+		return
+	}
+	out, err := install()
 	if err != nil {
 		errorResponse(err, res, errFailToInstallRelease)
 		return
@@ -188,16 +497,45 @@ func (rr *ReleaseResource) installRelease(req *restful.Request, res *restful.Res
 }
 
 // updateRelease updates the provided release
-func (rr *ReleaseResource) updateRelease(req *restful.Request, res *restful.Response) {
+func (rr *ReleaseResource) updateRelease(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
 	releaseName := req.PathParameter("release")
-	in := UpdateReleaseRequest{
-		Version: "latest",
-	}
+	in := newUpdateReleaseRequest()
 	if err := req.ReadEntity(&in); err != nil {
 		errorResponse(err, res, errFailToReadResponse)
 		return
 	}
-	out, err := rr.controller.UpdateRelease(releaseName, in.Repo, in.Chart, in.Version, in.Values)
+	if err := in.Validate(); err != nil {
+		errorResponse(err, res, errInvalidUpdateOptions)
+		return
+	}
+	values, err := in.merge()
+	if err != nil {
+		errorResponse(err, res, errInvalidValues)
+		return
+	}
+	install, _ := strconv.ParseBool(req.QueryParameter("install"))
+	ctx := requestContext(req)
+	update := func() (interface{}, error) {
+		var out *tiller.UpdateReleaseResponse
+		var err error
+		if install {
+			out, err = rc.UpsertRelease(ctx, releaseName, in.Namespace, in.Repo, in.Chart, in.Version, values, in.UpdateOptions)
+		} else {
+			out, err = rc.UpdateRelease(ctx, releaseName, in.Repo, in.Chart, in.Version, values, in.UpdateOptions)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if in.DryRun {
+			return controller.NewDryRunResponse(out.Release), nil
+		}
+		return out, nil
+	}
+	if isAsync(req) {
+		submitOperation(rr.operations, rr.clusters.ReleaseNamespace, req, res, "update", releaseName, in.Namespace, update)
+		return
+	}
+	out, err := update()
 	if err != nil {
 		errorResponse(err, res, errFailToUpdateRelease)
 		return
@@ -207,11 +545,34 @@ func (rr *ReleaseResource) updateRelease(req *restful.Request, res *restful.Resp
 	}
 }
 
+// isAsync checks if the operation should run in the background
+func isAsync(req *restful.Request) bool {
+	async, _ := strconv.ParseBool(req.QueryParameter("async"))
+	return async
+}
+
+// requestContext returns the context of the tiller calls made for the request. Async operations
+// outlive the request, so their calls aren't cancelled with it.
+func requestContext(req *restful.Request) context.Context {
+	if isAsync(req) {
+		return context.Background()
+	}
+	return req.Request.Context()
+}
+
 // uninstallRelease removes the release from the list of releases
-func (rr *ReleaseResource) uninstallRelease(req *restful.Request, res *restful.Response) {
+func (rr *ReleaseResource) uninstallRelease(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
 	releaseName := req.PathParameter("release")
 	_, purge := req.Request.URL.Query()["purge"]
-	out, err := rr.controller.UninstallRelease(releaseName, purge)
+	ctx := requestContext(req)
+	uninstall := func() (interface{}, error) {
+		return rc.UninstallRelease(ctx, releaseName, purge)
+	}
+	if isAsync(req) {
+		submitOperation(rr.operations, rr.clusters.ReleaseNamespace, req, res, "uninstall", releaseName, "", uninstall)
+		return
+	}
+	out, err := uninstall()
 	if err != nil {
 		errorResponse(err, res, errFailtToUninstallRelease)
 		return
@@ -221,13 +582,48 @@ func (rr *ReleaseResource) uninstallRelease(req *restful.Request, res *restful.R
 	}
 }
 
+// testRelease runs the tests of the provided release and streams the results
+func (rr *ReleaseResource) testRelease(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	timeout := int64(300)
+	if timeoutRaw := req.QueryParameter("timeout"); timeoutRaw != "" {
+		var err error
+		if timeout, err = strconv.ParseInt(timeoutRaw, 10, 64); err != nil || timeout < 0 {
+			errorResponse(err, res, errInvalidTestTimeout)
+			return
+		}
+	}
+	_, cleanup := req.Request.URL.Query()["cleanup"]
+
+	stream := newStreamWriter(req, res)
+	passed, err := rc.TestRelease(req.Request.Context(), name, timeout, cleanup, func(msg *controller.ReleaseTestMessage) error {
+		return stream.write("message", msg)
+	})
+	// nothing has been streamed yet, so a proper error response can still be sent
+	if err != nil && !stream.started {
+		errorResponse(err, res, errFailToTestRelease)
+		return
+	}
+	result := &controller.ReleaseTestMessage{
+		Done:   true,
+		Passed: passed,
+	}
+	if err != nil {
+		log.WithError(err).Error(errFailToTestRelease.Message)
+		result.Error = err.Error()
+	}
+	if err := stream.write("result", result); err != nil {
+		log.WithError(err).Error(errFailToWriteResponse.Message)
+	}
+}
+
 // getRelease returns the details of the provided release
-func (rr *ReleaseResource) getRelease(req *restful.Request, res *restful.Response) {
+func (rr *ReleaseResource) getRelease(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
 	name := req.PathParameter("release")
 	versionRaw := req.PathParameter("version")
 	version := util.ToInt32(versionRaw)
 
-	out, err := rr.controller.GetRelease(name, version)
+	out, err := rc.GetRelease(req.Request.Context(), name, version)
 	if err != nil {
 		errorResponse(err, res, errFailToGetRelease)
 		return
@@ -239,27 +635,212 @@ func (rr *ReleaseResource) getRelease(req *restful.Request, res *restful.Respons
 
 }
 
-// GET api/v1/releases/:name/:version/:status {create request body}
-func (rr *ReleaseResource) releaseStatus(req *restful.Request, res *restful.Response) {
-	// TODO
+// releaseObjects returns the kubernetes resources of the provided release
+func (rr *ReleaseResource) releaseObjects(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	version := util.ToInt32(req.PathParameter("version"))
+
+	objects, err := rc.ReleaseObjects(req.Request.Context(), name, version)
+	if err != nil {
+		errorResponse(err, res, errFailToGetReleaseObjects)
+		return
+	}
+	var out interface{} = objects
+	if req.QueryParameter("group-by") == "kind" {
+		out = controller.GroupByKind(objects)
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
+
+// releaseStatus returns the status of the provided release
+func (rr *ReleaseResource) releaseStatus(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	version := util.ToInt32(req.PathParameter("version"))
+
+	out, err := rc.ReleaseStatus(req.Request.Context(), name, version)
+	if err != nil {
+		errorResponse(err, res, errFailToGetReleaseStatus)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
+
+// rollbackRelease rolls back the provided release to a previous version
+func (rr *ReleaseResource) rollbackRelease(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	releaseName := req.PathParameter("release")
+	in := RollbackReleaseRequest{
+		Timeout: 300,
+	}
+	if err := req.ReadEntity(&in); err != nil {
+		errorResponse(err, res, errFailToReadResponse)
+		return
+	}
+	request := &tiller.RollbackReleaseRequest{
+		Name:         releaseName,
+		Version:      in.Version,
+		Recreate:     in.Recreate,
+		Force:        in.Force,
+		Wait:         in.Wait,
+		Timeout:      in.Timeout,
+		DisableHooks: in.DisableHooks,
+	}
+	out, err := rc.RollbackRelease(req.Request.Context(), request)
+	if err != nil {
+		errorResponse(err, res, errFailToRollbackRelease)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
+
+// releaseContent returns the content of the provided release
+func (rr *ReleaseResource) releaseContent(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	version := util.ToInt32(req.PathParameter("version"))
+
+	out, err := rc.ReleaseContent(req.Request.Context(), name, version)
+	if err != nil {
+		errorResponse(err, res, errFailToGetReleaseContent)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
+
+// releaseNotes returns the rendered notes of the provided release
+func (rr *ReleaseResource) releaseNotes(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	version := util.ToInt32(req.PathParameter("version"))
+
+	out, err := rc.ReleaseNotes(req.Request.Context(), name, version)
+	if err != nil {
+		errorResponse(err, res, errFailToGetReleaseNotes)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
+
+// releaseHooks returns the hooks of the provided release
+func (rr *ReleaseResource) releaseHooks(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	version := util.ToInt32(req.PathParameter("version"))
+
+	out, err := rc.ReleaseHooks(req.Request.Context(), name, version)
+	if err != nil {
+		errorResponse(err, res, errFailToGetReleaseHooks)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
+
+// diffRevisions returns the differences between two revisions of the provided release
+func (rr *ReleaseResource) diffRevisions(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	from, err := revisionParameter(req, "from")
+	if err != nil {
+		errorResponse(err, res, errInvalidRevision)
+		return
+	}
+	to, err := revisionParameter(req, "to")
+	if err != nil {
+		errorResponse(err, res, errInvalidRevision)
+		return
+	}
+
+	out, err := rc.DiffRevisions(req.Request.Context(), name, from, to)
+	if err != nil {
+		errorResponse(err, res, errFailToDiffRelease)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
 }
 
-// POST ??? I DUNNOT KNOW
-func (rr *ReleaseResource) rollbackRelease(req *restful.Request, res *restful.Response) {
-	// TODO
+// revisionParameter parses the revision in the query parameter. A missing parameter is revision 0.
+func revisionParameter(req *restful.Request, name string) (int32, error) {
+	raw := req.QueryParameter(name)
+	if raw == "" {
+		return 0, nil
+	}
+	revision, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if revision < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return int32(revision), nil
 }
 
-// GET /api/v1/releases/:name/:version {create request body}
-func (rr *ReleaseResource) releaseContent(req *restful.Request, res *restful.Response) {
-	// TODO
+// diffUpdate returns the differences between the deployed revision and the proposed update
+func (rr *ReleaseResource) diffUpdate(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	in := newUpdateReleaseRequest()
+	if err := req.ReadEntity(&in); err != nil {
+		errorResponse(err, res, errFailToReadResponse)
+		return
+	}
+	if err := in.Validate(); err != nil {
+		errorResponse(err, res, errInvalidUpdateOptions)
+		return
+	}
+	values, err := in.merge()
+	if err != nil {
+		errorResponse(err, res, errInvalidValues)
+		return
+	}
+	out, err := rc.DiffUpdate(req.Request.Context(), name, in.Repo, in.Chart, in.Version, values, in.UpdateOptions)
+	if err != nil {
+		errorResponse(err, res, errFailToDiffRelease)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
 }
 
-// ???? I DUNNOT KNOW
-func (rr *ReleaseResource) releaseHistory(req *restful.Request, res *restful.Response) {
-	// TODO
+// releaseHistory returns the revision history of the provided release
+func (rr *ReleaseResource) releaseHistory(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	name := req.PathParameter("release")
+	max := int32(256)
+	if maxRaw := req.QueryParameter("max"); maxRaw != "" {
+		parsed, err := strconv.ParseInt(maxRaw, 10, 32)
+		if err != nil || parsed < 1 {
+			errorResponse(err, res, errInvalidHistoryMax)
+			return
+		}
+		max = int32(parsed)
+	}
+
+	out, err := rc.ReleaseHistory(req.Request.Context(), name, max)
+	if err != nil {
+		errorResponse(err, res, errFailToGetReleaseHistory)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
 }
 
-// GET api/v1/version (do we need this?)
-func (rr *ReleaseResource) getVersion(req *restful.Request, res *restful.Response) {
-	// TODO
+// getVersion returns the rudder and tiller versions
+func (rr *ReleaseResource) getVersion(rc *controller.ReleaseController, req *restful.Request, res *restful.Response) {
+	out, err := rc.GetVersion(req.Request.Context(), rr.version)
+	if err != nil {
+		errorResponse(err, res, errFailToGetVersion)
+		return
+	}
+	if err := res.WriteEntity(out); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
 }
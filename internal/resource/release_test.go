@@ -0,0 +1,209 @@
+package resource
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emicklei/go-restful"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/helm/pkg/proto/hapi/release"
+	tiller "k8s.io/helm/pkg/proto/hapi/services"
+
+	"github.com/AcalephStorage/rudder/internal/client/fake"
+	"github.com/AcalephStorage/rudder/internal/controller"
+)
+
+// newTestClusters starts a fake tiller for the default and staging clusters and returns the
+// cluster controller connected to them. stop stops the fake tillers.
+func newTestClusters(t *testing.T) (servers map[string]*fake.TillerServer, clusters *controller.ClusterController, stop func()) {
+	servers = map[string]*fake.TillerServer{
+		"default": fake.NewTillerServer(),
+		"staging": fake.NewTillerServer(),
+	}
+	for _, ts := range servers {
+		ts.Start()
+	}
+	stop = func() {
+		for _, ts := range servers {
+			ts.Stop()
+		}
+	}
+	config := &controller.ClusterConfig{
+		Clusters: []*controller.Cluster{
+			{Name: "default", TillerAddress: "default:44134"},
+			{Name: "staging", TillerAddress: "staging:44134"},
+		},
+	}
+	newBackend := func(cluster *controller.Cluster) (controller.TillerBackend, error) {
+		return servers[cluster.Name].NewClient()
+	}
+	clusters, err := controller.NewClusterController(config, nil, nil, time.Minute, newBackend)
+	if err != nil {
+		stop()
+		t.Fatalf("unable to create the clusters: %v", err)
+	}
+	return servers, clusters, stop
+}
+
+// send sends the request to the container and returns the response
+func send(container *restful.Container, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set("Accept", restful.MIME_JSON)
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// newTestReleaseContainer returns a container with the release and cluster resources of the
+// clusters. stop stops running async operations.
+func newTestReleaseContainer(clusters *controller.ClusterController) (container *restful.Container, stop func()) {
+	container = restful.NewContainer()
+	operations := controller.NewOperationController(1, 10, time.Minute)
+	releases := NewReleaseResource(clusters, operations, nil, nil, "test")
+	releases.Register(container)
+	NewClusterResource(clusters, nil, releases).Register(container)
+	return container, operations.Stop
+}
+
+func TestReleaseRoutes(t *testing.T) {
+	servers, clusters, stop := newTestClusters(t)
+	defer stop()
+	deployed := fake.NewRelease("web", "team-a", 1)
+	deployed.Manifest = "deployed"
+	servers["default"].AddRelease(deployed)
+	failed := fake.NewRelease("web", "team-a", 2)
+	failed.Info.Status.Code = release.Status_FAILED
+	servers["default"].AddRelease(failed)
+	servers["staging"].AddRelease(fake.NewRelease("db", "team-b", 1))
+	container, stopOperations := newTestReleaseContainer(clusters)
+	defer stopOperations()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "list", method: http.MethodGet, path: "/api/v1/releases?status-code=DEPLOYED,FAILED", status: http.StatusOK},
+		{name: "list in a cluster", method: http.MethodGet, path: "/api/v1/clusters/staging/releases", status: http.StatusOK},
+		{name: "list in an unknown cluster", method: http.MethodGet, path: "/api/v1/clusters/missing/releases", status: http.StatusNotFound, code: codeNotFound},
+		{name: "content", method: http.MethodGet, path: "/api/v1/releases/web/0/content", status: http.StatusOK},
+		{name: "content of a missing release", method: http.MethodGet, path: "/api/v1/releases/missing/0/content", status: http.StatusNotFound, code: codeNotFound},
+		{name: "content of a release in another cluster", method: http.MethodGet, path: "/api/v1/releases/db/1/content", status: http.StatusNotFound, code: codeNotFound},
+		{name: "status", method: http.MethodGet, path: "/api/v1/clusters/staging/releases/db/1/status", status: http.StatusOK},
+		{name: "history", method: http.MethodGet, path: "/api/v1/releases/web/history", status: http.StatusOK},
+		{name: "diff from an invalid revision", method: http.MethodGet, path: "/api/v1/releases/web/diff?from=x", status: http.StatusBadRequest, code: codeBadRequest},
+		{name: "diff to a negative revision", method: http.MethodGet, path: "/api/v1/releases/web/diff?to=-2", status: http.StatusBadRequest, code: codeBadRequest},
+		{name: "history with an invalid max", method: http.MethodGet, path: "/api/v1/releases/web/history?max=abc", status: http.StatusBadRequest, code: codeBadRequest},
+		{name: "history without revisions", method: http.MethodGet, path: "/api/v1/releases/web/history?max=0", status: http.StatusBadRequest, code: codeBadRequest},
+		{name: "test with an invalid timeout", method: http.MethodPost, path: "/api/v1/releases/web/test?timeout=abc", status: http.StatusBadRequest, code: codeBadRequest},
+		{name: "test with a negative timeout", method: http.MethodPost, path: "/api/v1/releases/web/test?timeout=-1", status: http.StatusBadRequest, code: codeBadRequest},
+		{name: "rollback a missing release", method: http.MethodPost, path: "/api/v1/releases/missing/rollback", body: "{}", status: http.StatusNotFound, code: codeNotFound},
+		{name: "uninstall", method: http.MethodDelete, path: "/api/v1/clusters/staging/releases/db", status: http.StatusOK},
+		{name: "uninstall a missing release", method: http.MethodDelete, path: "/api/v1/clusters/staging/releases/missing", status: http.StatusNotFound, code: codeNotFound},
+	}
+	for _, test := range tests {
+		recorder := send(container, test.method, test.path, test.body)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d, want %d: %s", test.name, recorder.Code, test.status, recorder.Body)
+			continue
+		}
+		if test.code == "" {
+			continue
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || body.Code != test.code {
+			t.Errorf("%s: code = %q, %v, want %s", test.name, body.Code, err, test.code)
+		}
+	}
+}
+
+func TestReleaseContent(t *testing.T) {
+	servers, clusters, stop := newTestClusters(t)
+	defer stop()
+	deployed := fake.NewRelease("web", "team-a", 1)
+	deployed.Manifest = "deployed"
+	servers["default"].AddRelease(deployed)
+	failed := fake.NewRelease("web", "team-a", 2)
+	failed.Info.Status.Code = release.Status_FAILED
+	servers["default"].AddRelease(failed)
+	container, stopOperations := newTestReleaseContainer(clusters)
+	defer stopOperations()
+
+	for version, want := range map[string]int32{"0": 1, "1": 1, "2": 2} {
+		recorder := send(container, http.MethodGet, "/api/v1/releases/web/"+version+"/content", "")
+		var content tiller.GetReleaseContentResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &content); err != nil {
+			t.Errorf("version %s: unable to parse body: %v", version, err)
+			continue
+		}
+		if content.Release.GetVersion() != want {
+			t.Errorf("version %s: revision = %d, want %d", version, content.Release.GetVersion(), want)
+		}
+	}
+}
+
+func TestListReleases(t *testing.T) {
+	servers, clusters, stop := newTestClusters(t)
+	defer stop()
+	for _, name := range []string{"a", "b", "c"} {
+		servers["default"].AddRelease(fake.NewRelease(name, "default", 1))
+	}
+	container, stopOperations := newTestReleaseContainer(clusters)
+	defer stopOperations()
+
+	recorder := send(container, http.MethodGet, "/api/v1/releases?limit=2", "")
+	var page controller.ListReleasesResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatalf("unable to parse body: %v", err)
+	}
+	if page.Count != 2 || page.Total != 3 || page.Next != "c" || page.Releases[0].Name != "a" {
+		t.Errorf("page = %+v, want a and b followed by c", page)
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"unknown tiller error", errors.New("boom"), http.StatusInternalServerError, codeInternalError},
+		{"tiller unavailable", status.Error(codes.Unavailable, "down"), http.StatusServiceUnavailable, codeTillerUnavailable},
+	}
+	for _, test := range tests {
+		servers["default"].SetError(fake.ListReleases, test.err)
+		recorder := send(container, http.MethodGet, "/api/v1/releases", "")
+		if recorder.Code != test.status || !strings.Contains(recorder.Body.String(), test.code) {
+			t.Errorf("%s: status = %d, want %d with %s: %s", test.name, recorder.Code, test.status, test.code, recorder.Body)
+		}
+	}
+}
+
+func TestWatchStatusCodes(t *testing.T) {
+	_, clusters, stop := newTestClusters(t)
+	defer stop()
+	container, stopOperations := newTestReleaseContainer(clusters)
+	defer stopOperations()
+
+	for _, statusCodes := range []string{"bogus", "deployed,superseded", "deployed,"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases/watch?status-code="+statusCodes, nil)
+		req.Header.Set("Accept", mimeEventStream)
+		recorder := httptest.NewRecorder()
+		container.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), codeBadRequest) {
+			t.Errorf("%s: status = %d, want %d: %s", statusCodes, recorder.Code, http.StatusBadRequest, recorder.Body)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"k8s.io/helm/pkg/repo"
 
 	"github.com/AcalephStorage/rudder/internal/controller"
+	"github.com/AcalephStorage/rudder/internal/filter"
 )
 
 var (
@@ -19,11 +20,15 @@ var (
 // RepoResource represents helm repositories
 type RepoResource struct {
 	controller *controller.RepoController
+	authz      *filter.AuthzFilter
 }
 
 // NewRepoResource creates a new RepoResource
-func NewRepoResource(controller *controller.RepoController) *RepoResource {
-	return &RepoResource{controller: controller}
+func NewRepoResource(controller *controller.RepoController, authz *filter.AuthzFilter) *RepoResource {
+	return &RepoResource{
+		controller: controller,
+		authz:      authz,
+	}
 }
 
 // Register registers this resource to the provided container
@@ -40,12 +45,14 @@ func (rr *RepoResource) Register(container *restful.Container) {
 	ws.Route(ws.GET("").To(rr.listRepos).
 		Doc("list repos").
 		Operation("listRepos").
+		Filter(rr.authz.AuthorizeRepo(controller.VerbList)).
 		Writes([]repo.Entry{}))
 
 	// GET /api/v1/repo/{repo}/charts
 	ws.Route(ws.GET("{repo}/charts").To(rr.listCharts).
 		Doc("list charts").
 		Operation("listCharts").
+		Filter(rr.authz.AuthorizeRepo(controller.VerbList)).
 		Param(ws.PathParameter("repo", "the helm repository")).
 		Param(ws.QueryParameter("filter", "filter for the charts")).
 		Writes(map[string][]repo.ChartVersion{}))
@@ -54,6 +61,7 @@ func (rr *RepoResource) Register(container *restful.Container) {
 	ws.Route(ws.GET("{repo}/charts/{chart}").To(rr.listVersions).
 		Doc("list chart versions").
 		Operation("listVersions").
+		Filter(rr.authz.AuthorizeRepo(controller.VerbList)).
 		Param(ws.PathParameter("repo", "the helm repository")).
 		Param(ws.PathParameter("chart", "the helm chart")).
 		Writes([]repo.ChartVersion{}))
@@ -62,6 +70,7 @@ func (rr *RepoResource) Register(container *restful.Container) {
 	ws.Route(ws.GET("{repo}/charts/{chart}/{version}").To(rr.getChart).
 		Doc("get chart details. specifying version=latest will return the chart tagged latest, or the top version if none is found").
 		Operation("getChart").
+		Filter(rr.authz.AuthorizeRepo(controller.VerbGet)).
 		Param(ws.PathParameter("repo", "the helm repository")).
 		Param(ws.PathParameter("chart", "the helm chart")).
 		Param(ws.PathParameter("version", "the helm chart version")).
@@ -0,0 +1,66 @@
+package resource
+
+import (
+	"github.com/emicklei/go-restful"
+
+	"github.com/AcalephStorage/rudder/internal/controller"
+	"github.com/AcalephStorage/rudder/internal/filter"
+)
+
+// WebhookResource represents the webhooks notified of release lifecycle events
+type WebhookResource struct {
+	controller *controller.WebhookController
+	authz      *filter.AuthzFilter
+}
+
+// NewWebhookResource creates a new WebhookResource
+func NewWebhookResource(controller *controller.WebhookController, authz *filter.AuthzFilter) *WebhookResource {
+	return &WebhookResource{
+		controller: controller,
+		authz:      authz,
+	}
+}
+
+// Register registers this resource to the provided container
+func (wr *WebhookResource) Register(container *restful.Container) {
+
+	ws := new(restful.WebService)
+	ws.Path("/api/v1/webhooks").
+		Doc("Release lifecycle webhooks").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	// GET /api/v1/webhooks
+	ws.Route(ws.GET("").To(wr.listWebhooks).
+		Doc("list webhooks").
+		Operation("listWebhooks").
+		Filter(wr.authz.AuthorizeGlobal(controller.VerbAdmin)).
+		Writes([]controller.Webhook{}))
+
+	// GET /api/v1/webhooks/deliveries
+	ws.Route(ws.GET("/deliveries").To(wr.listDeliveries).
+		Doc("list the most recent webhook deliveries, newest first").
+		Operation("listDeliveries").
+		Filter(wr.authz.AuthorizeGlobal(controller.VerbAdmin)).
+		Param(ws.QueryParameter("webhook", "only list deliveries of this webhook")).
+		Writes([]controller.WebhookDelivery{}))
+
+	container.Add(ws)
+}
+
+// listWebhooks returns the configured webhooks
+func (wr *WebhookResource) listWebhooks(req *restful.Request, res *restful.Response) {
+	webhooks := wr.controller.ListWebhooks()
+	if err := res.WriteEntity(webhooks); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
+
+// listDeliveries returns the webhook delivery log
+func (wr *WebhookResource) listDeliveries(req *restful.Request, res *restful.Response) {
+	webhook := req.QueryParameter("webhook")
+	deliveries := wr.controller.ListDeliveries(webhook)
+	if err := res.WriteEntity(deliveries); err != nil {
+		errorResponse(err, res, errFailToWriteResponse)
+	}
+}
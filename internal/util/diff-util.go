@@ -0,0 +1,162 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DiffTooLarge ends the hunk header of a diff too large to be computed
+const DiffTooLarge = "diff too large"
+
+// diffOp is a single line of a line based diff. kind is one of ' ', '-' or '+'
+type diffOp struct {
+	kind  byte
+	text  string
+	aLine int
+	bLine int
+}
+
+// UnifiedDiff returns the unified diff between a and b with the given number of context lines.
+// An empty string is returned if there are no differences. If too many lines differ to be
+// compared, a single hunk header covering both inputs and ending with DiffTooLarge is returned.
+func UnifiedDiff(fromFile, toFile, a, b string, context int) string {
+	aLines, bLines := splitLines(a), splitLines(b)
+	ops, ok := diffLines(aLines, bLines)
+	if !ok {
+		return fmt.Sprintf("--- %s\n+++ %s\n@@ -1,%d +1,%d @@ %s\n", fromFile, toFile, len(aLines), len(bLines), DiffTooLarge)
+	}
+
+	var changes []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromFile, toFile)
+	for i := 0; i < len(changes); {
+		// group changes that are close enough to share context
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*context+1 {
+			j++
+		}
+		start := changes[i] - context
+		if start < 0 {
+			start = 0
+		}
+		end := changes[j] + context + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+		writeHunk(&out, ops[start:end])
+		i = j + 1
+	}
+	return out.String()
+}
+
+// writeHunk writes a single unified diff hunk
+func writeHunk(out *bytes.Buffer, ops []diffOp) {
+	aStart, bStart := ops[0].aLine+1, ops[0].bLine+1
+	var aLen, bLen int
+	for _, op := range ops {
+		if op.kind != '+' {
+			aLen++
+		}
+		if op.kind != '-' {
+			bLen++
+		}
+	}
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(op.text)
+		out.WriteByte('\n')
+	}
+}
+
+// maxDiffCells caps the size of the longest common subsequence table, about 16MB
+const maxDiffCells = 4 << 20
+
+// diffLines computes the line operations turning a into b using the longest common subsequence.
+// The common prefix and suffix are matched first. ok is false if the remaining lines are too many
+// to compare.
+func diffLines(a, b []string) (ops []diffOp, ok bool) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	n, m := len(a)-prefix-suffix, len(b)-prefix-suffix
+	if n > 0 && m > 0 && n > maxDiffCells/m {
+		return nil, false
+	}
+
+	ops = make([]diffOp, 0, len(a)+m)
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: ' ', text: a[i], aLine: i, bLine: i})
+	}
+	ops = appendLCS(ops, a[prefix:prefix+n], b[prefix:prefix+m], prefix)
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, diffOp{kind: ' ', text: a[prefix+n+i], aLine: prefix + n + i, bLine: prefix + m + i})
+	}
+	return ops, true
+}
+
+// appendLCS appends the line operations turning a into b. offset is the line of a[0] and b[0].
+func appendLCS(ops []diffOp, a, b []string, offset int) []diffOp {
+	n, m := len(a), len(b)
+	// lcs[i*(m+1)+j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([]int32, (n+1)*(m+1))
+	at := func(i, j int) int32 { return lcs[i*(m+1)+j] }
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(m+1)+j] = at(i+1, j+1) + 1
+			} else if at(i+1, j) >= at(i, j+1) {
+				lcs[i*(m+1)+j] = at(i+1, j)
+			} else {
+				lcs[i*(m+1)+j] = at(i, j+1)
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], aLine: offset + i, bLine: offset + j})
+			i++
+			j++
+		case j < m && (i == n || at(i, j+1) > at(i+1, j)):
+			ops = append(ops, diffOp{kind: '+', text: b[j], aLine: offset + i, bLine: offset + j})
+			j++
+		default:
+			ops = append(ops, diffOp{kind: '-', text: a[i], aLine: offset + i, bLine: offset + j})
+			i++
+		}
+	}
+	return ops
+}
+
+// splitLines splits s into lines, ignoring a trailing newline
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,110 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       string
+		b       string
+		context int
+		want    string
+	}{
+		{
+			name: "empty input",
+			want: "",
+		},
+		{
+			name: "no changes",
+			a:    "a\nb\nc\n",
+			b:    "a\nb\nc\n",
+			want: "",
+		},
+		{
+			name: "trailing newline is ignored",
+			a:    "a\nb",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "added to empty",
+			b:    "a\nb\n",
+			want: "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removed everything",
+			a:    "a\nb\n",
+			want: "--- from\n+++ to\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:    "changed line with context",
+			a:       "a\nb\nc\nd\ne\n",
+			b:       "a\nb\nC\nd\ne\n",
+			context: 1,
+			want:    "--- from\n+++ to\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+		},
+		{
+			name:    "added line",
+			a:       "a\nc\n",
+			b:       "a\nb\nc\n",
+			context: 3,
+			want:    "--- from\n+++ to\n@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+		{
+			name:    "distant changes are separate hunks",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:       "x\n2\n3\n4\n5\n6\n7\ny\n",
+			context: 1,
+			want:    "--- from\n+++ to\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+y\n",
+		},
+		{
+			name:    "close changes share a hunk",
+			a:       "1\n2\n3\n4\n",
+			b:       "x\n2\n3\ny\n",
+			context: 1,
+			want:    "--- from\n+++ to\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n-4\n+y\n",
+		},
+	}
+	for _, test := range tests {
+		got := UnifiedDiff("from", "to", test.a, test.b, test.context)
+		if got != test.want {
+			t.Errorf("%s: UnifiedDiff() =\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+}
+
+// numberedLines returns n lines starting with the prefix
+func numberedLines(prefix string, n int) string {
+	var lines []string
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("%s%d", prefix, i))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestUnifiedDiffLargeInput(t *testing.T) {
+	// a single change in a large object is found after matching the common lines
+	a := numberedLines("line", 20000)
+	b := strings.Replace(a, "line10000\n", "changed\n", 1)
+	want := "--- from\n+++ to\n@@ -10001,1 +10001,1 @@\n-line10000\n+changed\n"
+	if got := UnifiedDiff("from", "to", a, b, 0); got != want {
+		t.Errorf("single change: UnifiedDiff() =\n%q\nwant\n%q", got, want)
+	}
+
+	// too many different lines are not compared
+	a = "same\n" + numberedLines("a", 10000)
+	b = "same\n" + numberedLines("b", 10000)
+	want = "--- from\n+++ to\n@@ -1,10001 +1,10001 @@ " + DiffTooLarge + "\n"
+	if got := UnifiedDiff("from", "to", a, b, 3); got != want {
+		t.Errorf("rewritten object: UnifiedDiff() = %q, want %q", got, want)
+	}
+
+	// but an added or removed large object is
+	if got := UnifiedDiff("from", "to", "", b, 0); strings.Count(got, "\n+b") != 10000 {
+		t.Errorf("added object: got %d added lines, want 10000", strings.Count(got, "\n+b"))
+	}
+}
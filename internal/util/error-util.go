@@ -0,0 +1,56 @@
+package util
+
+import (
+	"fmt"
+)
+
+// wrappedError is an error of a known kind, eg. a sentinel error, caused by another error
+type wrappedError struct {
+	kind  error
+	cause error
+}
+
+// WrapError wraps the cause with the kind of error. The kind can be checked with IsError while the
+// message still contains the cause.
+func WrapError(kind, cause error) error {
+	return &wrappedError{kind: kind, cause: cause}
+}
+
+// Error returns the kind and the cause
+func (we *wrappedError) Error() string {
+	return fmt.Sprintf("%v: %v", we.kind, we.cause)
+}
+
+// Cause returns the wrapped error
+func (we *wrappedError) Cause() error {
+	return we.cause
+}
+
+// IsError checks if the error is, or wraps, the kind of error
+func IsError(err, kind error) bool {
+	for err != nil {
+		if err == kind {
+			return true
+		}
+		we, ok := err.(*wrappedError)
+		if !ok {
+			return false
+		}
+		if we.kind == kind {
+			return true
+		}
+		err = we.cause
+	}
+	return false
+}
+
+// RootCause returns the innermost wrapped error
+func RootCause(err error) error {
+	for {
+		we, ok := err.(*wrappedError)
+		if !ok {
+			return err
+		}
+		err = we.cause
+	}
+}